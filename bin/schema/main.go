@@ -0,0 +1,17 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/kairos-io/kairos-sdk/schema"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	app := &cli.App{Commands: schema.CliCommands()}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultCmdlineExcludes are the kernel, systemd and dracut parameters that
+// are never merged from the cmdline, unless Options.CmdlineExcludes is set.
+// They are globs matched against the whole key, e.g. "rd.*" matches
+// "rd.luks.uuid".
+var DefaultCmdlineExcludes = []string{
+	"BOOT_IMAGE", "initrd", "init", "root", "rootfstype", "rootflags", "rootwait",
+	"ro", "rw", "console", "earlyprintk", "quiet", "splash", "loglevel",
+	"panic", "nomodeset", "vga", "video", "fbcon", "resume", "noresume", "ip",
+	"net.ifnames", "biosdevname", "selinux", "enforcing", "apparmor", "security",
+	"audit", "lsm", "fips", "mitigations", "iommu", "intel_iommu", "amd_iommu",
+	"modprobe.blacklist", "module_blacklist", "cgroup_enable", "cgroup_memory",
+	"systemd.*", "rd.*", "rootdelay", "cdroot", "cdroot_label", "boot",
+}
+
+// cmdlineKeys returns the keys of the cmdline, in dot notation, to be merged.
+// If namespaces or an allowlist are set, only the keys under a namespace, with
+// the namespace removed, and the allowed ones are returned. Excluded keys are
+// never returned.
+func (o *Options) cmdlineKeys(dot map[string]interface{}) map[string]interface{} {
+	excludes := o.CmdlineExcludes
+	if excludes == nil {
+		excludes = DefaultCmdlineExcludes
+	}
+	namespaced := len(o.CmdlineNamespaces) > 0 || len(o.CmdlineAllowlist) > 0
+
+	result := map[string]interface{}{}
+	namespacedKeys := map[string]interface{}{}
+	for key, value := range dot {
+		if matchesKey(excludes, key) {
+			continue
+		}
+		if !namespaced || matchesKey(o.CmdlineAllowlist, key) {
+			result[key] = value
+		}
+		for _, ns := range o.CmdlineNamespaces {
+			if k := strings.TrimPrefix(key, ns); k != key && k != "" {
+				namespacedKeys[k] = value
+			}
+		}
+	}
+	// Namespaced keys override the same ones without namespace.
+	for key, value := range namespacedKeys {
+		result[key] = value
+	}
+	return result
+}
+
+// matchesKey returns true if the key, or one of its parents, matches one of
+// the globs.
+func matchesKey(globs []string, key string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, key); ok || strings.HasPrefix(key, g+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package collector_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cmdline keys", func() {
+	var tmpDir, cmdline string
+	var err error
+
+	noFilter := func(d []byte) ([]byte, error) { return d, nil }
+	scan := func(opts ...Option) Config {
+		o := &Options{}
+		Expect(o.Apply(append([]Option{NoLogs, MergeBootLine, WithBootCMDLineFile(cmdline)}, opts...)...)).To(Succeed())
+		c, err := Scan(o, noFilter)
+		Expect(err).ToNot(HaveOccurred())
+		return *c
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "cmdline")
+		Expect(err).ToNot(HaveOccurred())
+		cmdline = filepath.Join(tmpDir, "cmdline")
+		Expect(os.WriteFile(cmdline, []byte(`BOOT_IMAGE=/boot/vmlinuz console=tty1 root=LABEL=COS_ACTIVE rd.neednet=1 rd.cos.disable `+
+			`systemd.unit=multi-user.target install.device=/dev/sda kairos.install.device=/dev/vda cos.hostname=foo config_url=http://example.com`), os.ModePerm)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("excludes kernel and dracut parameters by default", func() {
+		Expect(scan()).To(Equal(Config{
+			"install":    Config{"device": "/dev/sda"},
+			"kairos":     Config{"install": Config{"device": "/dev/vda"}},
+			"cos":        Config{"hostname": "foo"},
+			"config_url": "http://example.com",
+		}))
+	})
+
+	It("only merges namespaced and allowed keys", func() {
+		Expect(scan(CmdlineNamespaces("kairos", "cos."))).To(Equal(Config{
+			"install":  Config{"device": "/dev/vda"},
+			"hostname": "foo",
+		}))
+
+		Expect(scan(CmdlineNamespaces("kairos"), CmdlineAllowlist("config_url", "install"))).To(Equal(Config{
+			"install":    Config{"device": "/dev/vda"},
+			"config_url": "http://example.com",
+		}))
+	})
+
+	It("can exclude other keys", func() {
+		c := scan(CmdlineExcludes())
+		Expect(c).To(HaveKey("BOOT_IMAGE"))
+		Expect(c).To(HaveKeyWithValue("rd", Config{"neednet": 1, "cos": Config{"disable": true}}))
+
+		c = scan(CmdlineExcludes("kairos", "cos", "install"))
+		Expect(c).To(HaveKey("root"))
+		Expect(c).ToNot(HaveKey("kairos"))
+		Expect(c).ToNot(HaveKey("install"))
+	})
+
+	It("validates the options", func() {
+		o := &Options{}
+		Expect(o.Apply(CmdlineNamespaces("."))).ToNot(Succeed())
+		Expect(o.Apply(CmdlineAllowlist("["))).ToNot(Succeed())
+	})
+})
@@ -3,21 +3,19 @@
 package collector
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"path/filepath"
 	"reflect"
 	"strings"
-	"time"
 	"unicode"
 
 	"github.com/kairos-io/kairos-sdk/machine"
+	"github.com/rs/zerolog"
 
-	"github.com/avast/retry-go"
-	"github.com/itchyny/gojq"
+	"github.com/kairos-io/kairos-sdk/unstructured"
 	"gopkg.in/yaml.v3"
 )
 
@@ -38,29 +36,90 @@ type Config map[string]interface{}
 // MergeConfigURL looks for the "config_url" key and if it's found
 // it downloads the remote config and merges it with the current one.
 // If the remote config also has config_url defined, it is also fetched
-// recursively until a remote config no longer defines a config_url.
+// recursively until a remote config no longer defines a config_url, failing
+// with a ConfigURLError if the chain loops or is longer than DefaultMaxConfigURLDepth.
+// If "config_url_sha256" is set, the remote config must match that checksum.
+// Remote configs that can't be fetched are skipped, and the ones with an
+// invalid signature, see SignaturePolicy, or that can't be decrypted are
+// rejected like files are: they are skipped, and with StrictValidation Scan
+// fails after merging the rest.
 // NOTE: The "config_url" value of the final result is the value of the last
 // config file in the chain because we replace values when we merge.
 func (c *Config) MergeConfigURL() error {
+	return c.mergeConfigURL(Provenance{}, newConfigURLChain(newScanState(&Options{})))
+}
+
+// mergeConfigURL is MergeConfigURL but it also records in p the provenance of
+// the keys coming from the remote configs.
+func (c *Config) mergeConfigURL(p Provenance, chain *configURLChain) error {
 	// If there is no config_url, just return (do nothing)
 	configURL := c.ConfigURL()
 	if configURL == "" {
 		return nil
 	}
 
+	if err := chain.visit(configURL); err != nil {
+		return err
+	}
+
+	source := Source{Kind: SourceConfigURL, Location: configURL}
+
 	// fetch the remote config
-	remoteConfig, err := fetchRemoteConfig(configURL)
+	body, err := chain.state.fetch(configURL)
 	if err != nil {
+		chain.state.skip(source, zerolog.WarnLevel, fmt.Sprintf("couldn't fetch config_url: %s", err))
+		return nil
+	}
+
+	if err := chain.verify(body, c.ConfigURLSHA256()); err != nil {
 		return err
 	}
 
+	keys, err := c.cosignKeys(chain.state.fetch)
+	if err != nil {
+		chain.state.reject(source, "cosign-key", err)
+		return nil
+	}
+	fetchSignature := func() ([]byte, error) { return chain.state.fetch(configURL + SignatureSuffix) }
+	if err := chain.state.checkSignature(SourceConfigURL, body, fetchSignature, keys); err != nil {
+		chain.state.reject(source, "signature", err)
+		return nil
+	}
+
+	if !HasValidHeader(string(body)) {
+		chain.state.skip(source, zerolog.WarnLevel, "no valid header")
+		return nil
+	}
+
+	body, err = chain.state.render(body)
+	if err != nil {
+		return fmt.Errorf("could not render remote config: %w", err)
+	}
+
+	remoteConfig := &Config{}
+	if err := yaml.Unmarshal(body, remoteConfig); err != nil {
+		return fmt.Errorf("could not unmarshal remote config to an object: %w", err)
+	}
+	if err := chain.state.decryptSections(remoteConfig); err != nil {
+		chain.state.reject(source, "couldn't decrypt", err)
+		return nil
+	}
+	chain.state.accept(source)
+	chain.state.validateSource(source, body, remoteConfig)
+	remoteProvenance := newProvenance(source, remoteConfig)
+
 	// recursively fetch remote configs
-	if err := remoteConfig.MergeConfigURL(); err != nil {
+	if err := remoteConfig.mergeConfigURL(remoteProvenance, chain); err != nil {
 		return err
 	}
 
 	// merge remoteConfig back to "c"
-	return c.MergeConfig(remoteConfig)
+	if err := c.MergeConfig(remoteConfig); err != nil {
+		return err
+	}
+	p.merge(remoteProvenance)
+
+	return nil
 }
 
 func (c *Config) toMap() (map[string]interface{}, error) {
@@ -111,13 +170,13 @@ func (c *Config) MergeConfig(newConfig *Config) error {
 func mergeSlices(sliceA, sliceB []interface{}) ([]interface{}, error) {
 	// return sliceB if sliceA is empty
 	if len(sliceA) == 0 {
-		return sliceB, nil
+		return stripDirectives(sliceB).([]interface{}), nil
 	}
 	// We use the first item in the slice to determine if there are maps present.
 	firstItem := sliceA[0]
 	// If the first item is a map, we concatenate both slices
 	if reflect.ValueOf(firstItem).Kind() == reflect.Map {
-		union := append(sliceA, sliceB...)
+		union := append(sliceA, stripDirectives(sliceB).([]interface{})...)
 
 		return union, nil
 	}
@@ -149,19 +208,27 @@ func mergeSlices(sliceA, sliceB []interface{}) ([]interface{}, error) {
 }
 
 func deepMergeMaps(a, b map[string]interface{}) (map[string]interface{}, error) {
+	directives, err := mergeDirectives(b)
+	if err != nil {
+		return a, err
+	}
+
 	// go through all items in b and merge them to a
 	for k, v := range b {
+		if k == MergeDirectivesKey {
+			continue
+		}
 		current, ok := a[k]
 		if ok {
 			// when the key is already set, we don't know what type it has, so we deep merge them in case they are maps
-			// or slices
-			res, err := DeepMerge(current, v)
+			// or slices, unless b defines a different strategy for it
+			res, err := mergeWithStrategy(current, v, strategyFor(directives, k))
 			if err != nil {
 				return a, err
 			}
 			a[k] = res
 		} else {
-			a[k] = v
+			a[k] = stripDirectives(v)
 		}
 	}
 
@@ -170,9 +237,10 @@ func deepMergeMaps(a, b map[string]interface{}) (map[string]interface{}, error)
 
 // DeepMerge takes two data structures and merges them together deeply. The results can vary depending on how the
 // arguments are passed since structure B will always overwrite what's on A.
+// Maps in B can change how their keys are merged with a MergeDirectivesKey.
 func DeepMerge(a, b interface{}) (interface{}, error) {
 	if a == nil && b != nil {
-		return b, nil
+		return stripDirectives(b), nil
 	}
 
 	typeA := reflect.TypeOf(a)
@@ -215,173 +283,272 @@ func (c *Config) String() (string, error) {
 	return fmt.Sprintf("%s\n\n%s", DefaultHeader, string(data)), nil
 }
 
+// Merge merges all the configs in order, resolving their config_url first.
 func (cs Configs) Merge() (*Config, error) {
+	s := newScanState(&Options{})
+	return cs.merge(nil, s)
+}
+
+// merge is Merge but it also records the Provenance of the result in the
+// report of the state. sources must be either nil or have a Source for every config.
+func (cs Configs) merge(sources []Source, s *scanState) (*Config, error) {
 	result := &Config{}
 
-	for _, c := range cs {
-		if err := c.MergeConfigURL(); err != nil {
+	for i, c := range cs {
+		source := Source{Kind: SourceReader, Location: fmt.Sprint(i)}
+		if sources != nil {
+			source = sources[i]
+		}
+		p := newProvenance(source, c)
+
+		if err := c.mergeConfigURL(p, newConfigURLChain(s)); err != nil {
 			return result, err
 		}
 
 		if err := result.MergeConfig(c); err != nil {
 			return result, err
 		}
+		s.report.Provenance.merge(p)
 	}
 
 	return result, nil
 }
 
+// Scan reads and merges the configuration from all the sources defined in the
+// Options, see ScanLayers.
 func Scan(o *Options, filter func(d []byte) ([]byte, error)) (*Config, error) {
-	configs := Configs{}
+	result, _, err := ScanWithReport(o, filter)
+	return result, err
+}
 
-	configs = append(configs, parseFiles(o.ScanDir, o.NoLogs)...)
-	configs = append(configs, parseReaders(o.Readers, o.NoLogs)...)
+// ScanWithProvenance is like Scan, but it also returns the Provenance of every
+// key of the merged Config, so it's possible to know which file, reader, URL
+// or cmdline set each value and which ones it overrode.
+func ScanWithProvenance(o *Options, filter func(d []byte) ([]byte, error)) (*Config, Provenance, error) {
+	result, report, err := ScanWithReport(o, filter)
+	return result, report.Provenance, err
+}
 
-	if o.MergeBootCMDLine {
-		cConfig, err := ParseCmdLine(o.BootCMDLineFile, filter)
-		o.SoftErr("parsing cmdline", err)
-		if err == nil { // best-effort
-			configs = append(configs, cConfig)
-		}
+// ScanWithReport is like Scan, but it also returns a ScanReport with the
+// sources that were merged, the ones that were skipped and why, and the
+// Provenance of the result.
+func ScanWithReport(o *Options, filter func(d []byte) ([]byte, error)) (*Config, *ScanReport, error) {
+	s := newScanState(o)
+	layers, err := s.scanLayers(o, filter)
+	if err != nil {
+		return &Config{}, s.report, err
 	}
-
-	mergedConfig, err := configs.Merge()
+	mergedConfig, err := layers.Effective()
 	if err != nil {
-		return mergedConfig, err
+		return mergedConfig, s.report, err
 	}
 
-	if o.Overwrites != "" {
-		yaml.Unmarshal([]byte(o.Overwrites), &mergedConfig) //nolint:errcheck
+	if err := mergedConfig.ResolveSecrets(); err != nil {
+		return mergedConfig, s.report, err
 	}
 
-	return mergedConfig, nil
-}
-
-func allFiles(dir []string) []string {
-	files := []string{}
-	for _, d := range dir {
-		if f, err := listFiles(d); err == nil {
-			files = append(files, f...)
-		}
+	if err := s.validateResult(mergedConfig); err != nil {
+		return mergedConfig, s.report, err
 	}
-	return files
+
+	return mergedConfig, s.report, nil
 }
 
-// parseFiles returns a list of Configs parsed from files.
-func parseFiles(dir []string, nologs bool) Configs {
+// parseFiles returns a list of Configs parsed from files and the Source of each one of them.
+// Files with several YAML documents return a Config for each document with a header.
+func parseFiles(dir []string, s *scanState) (Configs, []Source) {
 	result := Configs{}
-	files := allFiles(dir)
+	sources := []Source{}
+	files := s.listConfigFiles(dir)
 	for _, f := range files {
-		if fileSize(f) > 1.0 {
-			if !nologs {
-				fmt.Printf("warning: skipping %s. too big (>1MB)\n", f)
-			}
+		source := Source{Kind: SourceFile, Location: f}
+		if info, err := os.Stat(f); err == nil && info.Size() > s.maxFileSize {
+			s.skip(source, zerolog.WarnLevel, fmt.Sprintf("too big (>%s)", formatSize(s.maxFileSize)))
 			continue
 		}
-		if filepath.Ext(f) == ".yml" || filepath.Ext(f) == ".yaml" {
-			b, err := os.ReadFile(f)
-			if err != nil {
-				if !nologs {
-					fmt.Printf("warning: skipping %s. %s\n", f, err.Error())
-				}
+		format, ok := formatOf(f)
+		if !ok {
+			s.skip(source, zerolog.DebugLevel, "extension")
+			continue
+		}
+
+		b, err := os.ReadFile(f)
+		if err != nil {
+			s.skip(source, zerolog.WarnLevel, err.Error())
+			continue
+		}
+
+		docs := splitDocuments(format, b)
+		if !anyHasHeader(format, docs) {
+			s.skip(source, zerolog.WarnLevel, "no valid header")
+			continue
+		}
+
+		fetchSignature := func() ([]byte, error) { return os.ReadFile(f + SignatureSuffix) }
+		if err := s.checkSignature(SourceFile, b, fetchSignature, nil); err != nil {
+			s.reject(source, "signature", err)
+			continue
+		}
+
+		for i, doc := range docs {
+			docSource := source
+			if len(docs) > 1 {
+				docSource.Document = i + 1
+			}
+			if !hasHeader(format, doc.content) {
+				s.skip(docSource, zerolog.WarnLevel, "no valid header")
 				continue
 			}
-
-			if !HasValidHeader(string(b)) {
-				if !nologs {
-					fmt.Printf("warning: skipping %s because it has no valid header\n", f)
-				}
+			newConfig, content, ok := s.parseDocument(docSource, format, doc)
+			if !ok {
 				continue
 			}
+			result = append(result, newConfig)
+			sources = append(sources, docSource)
+			s.accept(docSource)
+			s.validateSource(docSource, content, newConfig)
+		}
+	}
 
-			var newConfig Config
-			err = yaml.Unmarshal(b, &newConfig)
-			if err != nil && !nologs {
-				fmt.Printf("warning: failed to parse config:\n%s\n", err.Error())
-			}
-			result = append(result, &newConfig)
-		} else {
-			if !nologs {
-				fmt.Printf("warning: skipping %s (extension).\n", f)
-			}
+	return result, sources
+}
+
+func anyHasHeader(format Format, docs []document) bool {
+	for _, doc := range docs {
+		if hasHeader(format, doc.content) {
+			return true
 		}
 	}
+	return false
+}
+
+// parseDocument renders, decodes and decrypts a document of a file. It returns
+// the content to locate validation errors with, or false if the source was
+// rejected.
+func (s *scanState) parseDocument(source Source, format Format, doc document) (*Config, []byte, bool) {
+	if format == FormatYAML && strings.HasPrefix(strings.TrimSpace(string(doc.content)), "#node-config") {
+		s.logger.Logger.Warn().
+			Str("kind", string(source.Kind)).
+			Str("source", source.Location).
+			Msg("the #node-config header is deprecated, use #cloud-config")
+	}
+	content, err := s.render(doc.content)
+	if err != nil {
+		s.reject(source, "invalid template", err)
+		return nil, nil, false
+	}
 
-	return result
+	newConfig, err := decodeDocument(format, content)
+	if err != nil {
+		s.reject(source, "invalid "+strings.ToUpper(string(format)), err)
+		return nil, nil, false
+	}
+	if err := s.decryptSections(newConfig); err != nil {
+		s.reject(source, "couldn't decrypt", err)
+		return nil, nil, false
+	}
+
+	if format == FormatTOML {
+		// Validation errors can only be located in YAML and JSON.
+		return newConfig, nil, true
+	}
+	doc.content = content
+	return newConfig, doc.withLines(), true
 }
 
 // parseReaders returns a list of Configs parsed from Reader interfaces
 // We assume as this has been passed explicitly to the collector that the
 // checks for it being a config is already done, so no header checks here.
-func parseReaders(readers []io.Reader, nologs bool) Configs {
+// Readers with several YAML documents return a Config for each document.
+func parseReaders(readers []io.Reader, s *scanState) (Configs, []Source) {
 	result := Configs{}
-	for _, R := range readers {
-		var newConfig Config
+	sources := []Source{}
+	for i, R := range readers {
+		source := Source{Kind: SourceReader, Location: fmt.Sprint(i)}
 		read, err := io.ReadAll(R)
 		if err != nil {
-			if !nologs {
-				fmt.Printf("Error reading config: %s", err.Error())
-			}
+			s.skip(source, zerolog.WarnLevel, fmt.Sprintf("error reading config: %s", err))
 			continue
 		}
-		err = yaml.Unmarshal(read, &newConfig)
+		read, err = s.render(read)
 		if err != nil {
-			err = json.Unmarshal(read, &newConfig)
+			s.reject(source, "invalid template", err)
+			continue
+		}
+
+		docs := splitDocuments(FormatYAML, read)
+		for j, doc := range docs {
+			docSource := source
+			if len(docs) > 1 {
+				docSource.Document = j + 1
+			}
+			var newConfig Config
+			err = yaml.Unmarshal(doc.content, &newConfig)
 			if err != nil {
-				if !nologs {
-					fmt.Printf("Error unmarshalling config(error: %s): %s", err.Error(), string(read))
+				err = json.Unmarshal(doc.content, &newConfig)
+				if err != nil {
+					s.reject(docSource, "invalid YAML", err)
+					continue
 				}
+			}
+			if err := s.decryptSections(&newConfig); err != nil {
+				s.reject(docSource, "couldn't decrypt", err)
 				continue
 			}
+			result = append(result, &newConfig)
+			sources = append(sources, docSource)
+			s.accept(docSource)
+			s.validateSource(docSource, doc.withLines(), &newConfig)
 		}
-		result = append(result, &newConfig)
 	}
 
-	return result
+	return result, sources
 }
 
-func fileSize(f string) float64 {
-	file, err := os.Open(f)
-	if err != nil {
-		return 0
-	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return 0
+// cmdlineFile returns the file ParseCmdLine reads when given the file f.
+func cmdlineFile(f string) string {
+	if f == "" {
+		return "/proc/cmdline"
 	}
-
-	bytes := stat.Size()
-	kilobytes := (bytes / 1024)
-	megabytes := (float64)(kilobytes / 1024) // cast to type float64
-
-	return megabytes
+	return f
 }
 
-func listFiles(dir string) ([]string, error) {
-	content := []string{}
-
-	err := filepath.Walk(dir,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			if !info.IsDir() {
-				content = append(content, path)
-			}
-
-			return nil
-		})
-
-	return content, err
-}
+// CmdlineConfigKey is the cmdline key carrying a whole base64 encoded config,
+// e.g. config.b64=I2Nsb3VkLWNvbmZpZwpob3N0bmFtZTogZm9vCg==. The other keys of
+// the cmdline are merged on top of it.
+const CmdlineConfigKey = "config.b64"
 
 // ParseCmdLine reads options from the kernel cmdline and returns the equivalent
-// Config.
+// Config. Values are typed, keys set more than once become lists and keys can
+// index lists, like users[0].name=kairos. Configs encoded in CmdlineConfigKey
+// are not filtered. Kernel and dracut parameters in DefaultCmdlineExcludes are
+// ignored.
 func ParseCmdLine(file string, filter func(d []byte) ([]byte, error)) (*Config, error) {
+	return parseCmdLine(file, filter, &Options{})
+}
+
+// parseCmdLine is ParseCmdLine, but only the keys selected by the cmdline
+// options are read, see Options.CmdlineNamespaces.
+func parseCmdLine(file string, filter func(d []byte) ([]byte, error), o *Options) (*Config, error) {
 	result := Config{}
-	dotToYAML, err := machine.DotToYAML(file)
+	dot, err := machine.CmdlineToDot(file)
+	if err != nil {
+		return &result, err
+	}
+	dotToYAML, err := unstructured.ToYAML(o.cmdlineKeys(dot))
+	if err != nil {
+		return &result, err
+	}
+
+	cmdline := Config{}
+	if err := yaml.Unmarshal(dotToYAML, &cmdline); err != nil {
+		return &result, err
+	}
+	encoded, err := cmdline.popEncodedConfigs()
+	if err != nil {
+		return &result, err
+	}
+	dotToYAML, err = yaml.Marshal(cmdline)
 	if err != nil {
 		return &result, err
 	}
@@ -396,7 +563,53 @@ func ParseCmdLine(file string, filter func(d []byte) ([]byte, error)) (*Config,
 		return &result, err
 	}
 
-	return &result, nil
+	if encoded == nil {
+		return &result, nil
+	}
+	if err := encoded.MergeConfig(&result); err != nil {
+		return &result, err
+	}
+	return encoded, nil
+}
+
+// popEncodedConfigs removes the CmdlineConfigKey from the cmdline Config and
+// returns the configs encoded in it merged in order, or nil if there are none.
+func (c Config) popEncodedConfigs() (*Config, error) {
+	parent, key, _ := strings.Cut(CmdlineConfigKey, ".")
+	section, ok := c[parent].(Config)
+	if !ok {
+		return nil, nil
+	}
+	value, found := section[key]
+	if !found {
+		return nil, nil
+	}
+	delete(section, key)
+	if len(section) == 0 {
+		delete(c, parent)
+	}
+
+	values, isList := value.([]interface{})
+	if !isList {
+		values = []interface{}{value}
+	}
+
+	result := &Config{}
+	for _, v := range values {
+		s := strings.TrimRight(fmt.Sprint(v), "=")
+		dat, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", CmdlineConfigKey, err)
+		}
+		decoded := Config{}
+		if err := yaml.Unmarshal(dat, &decoded); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", CmdlineConfigKey, err)
+		}
+		if err := result.MergeConfig(&decoded); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
 // ConfigURL returns the value of config_url if set or empty string otherwise.
@@ -410,49 +623,6 @@ func (c Config) ConfigURL() string {
 	return ""
 }
 
-func fetchRemoteConfig(url string) (*Config, error) {
-	var body []byte
-	result := &Config{}
-
-	err := retry.Do(
-		func() error {
-			resp, err := http.Get(url)
-			if err != nil {
-				return err
-			}
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("unexpected status: %d", resp.StatusCode)
-			}
-			defer resp.Body.Close()
-
-			body, err = io.ReadAll(resp.Body)
-			if err != nil {
-				return err
-			}
-
-			return nil
-		}, retry.Delay(time.Second), retry.Attempts(3),
-	)
-
-	if err != nil {
-		// TODO: improve logging
-		fmt.Printf("WARNING: Couldn't fetch config_url: %s", err)
-		return result, nil
-	}
-
-	if !HasValidHeader(string(body)) {
-		// TODO: Print a warning when we implement proper logging
-		fmt.Println("No valid header in remote config: %w", err)
-		return result, nil
-	}
-
-	if err := yaml.Unmarshal(body, result); err != nil {
-		return result, fmt.Errorf("could not unmarshal remote config to an object: %w", err)
-	}
-
-	return result, nil
-}
-
 func HasValidHeader(data string) bool {
 	// Get the first 10 lines
 	headers := strings.SplitN(data, "\n", 10)
@@ -476,50 +646,25 @@ func HasValidHeader(data string) bool {
 	return false
 }
 
+// Query runs the jq expression, or dot separated path like "install.device",
+// on the Config and returns the values found as YAML, skipping null ones.
 func (c Config) Query(s string) (res string, err error) {
-	s = fmt.Sprintf(".%s", s)
-
-	var dat map[string]interface{}
-	var dat1 map[string]interface{}
-
-	yamlStr, err := c.String()
-	if err != nil {
-		panic(err)
-	}
-	// Marshall it so it removes the first line which cannot be parsed
-	err = yaml.Unmarshal([]byte(yamlStr), &dat1)
+	values, err := c.QueryValues(s)
 	if err != nil {
-		panic(err)
+		return res, fmt.Errorf("failed parsing, error: %w", err)
 	}
-	// Transform it to json so its parsed correctly by gojq
-	b, err := json.Marshal(dat1)
-	if err != nil {
-		panic(err)
-	}
-	if err := json.Unmarshal(b, &dat); err != nil {
-		panic(err)
-	}
-	// Adding some jq options to the query so the output does not include "null" if the value is empty
-	// This is not a json parse feature but a string one, so we should return normal values not json specific ones
-	query, err := gojq.Parse(s + " | if ( . | type) == \"null\" then empty else . end")
-	if err != nil {
-		return res, err
-	}
-	iter := query.Run(dat) // or query.RunWithContext
-	for {
-		v, ok := iter.Next()
-		if !ok {
-			break
-		}
-		if err, ok := v.(error); ok {
-			return res, fmt.Errorf("failed parsing, error: %w", err)
-		}
 
-		dat, err := yaml.Marshal(v)
-		if err != nil {
-			break
+	nonNull := unstructured.QueryValues{}
+	for _, v := range values {
+		if !v.IsNull() {
+			nonNull = append(nonNull, v)
 		}
-		res += string(dat)
 	}
-	return
+	return nonNull.Format(unstructured.QueryOutputYAML)
+}
+
+// QueryValues runs the jq expression, or dot separated path like
+// "install.device", on the Config and returns the values it produces.
+func (c Config) QueryValues(expression string, opts ...unstructured.QueryOption) (unstructured.QueryValues, error) {
+	return unstructured.Query(expression, c, opts...)
 }
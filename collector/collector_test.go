@@ -2,6 +2,7 @@ package collector_test
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	. "github.com/kairos-io/kairos-sdk/collector"
+	"github.com/kairos-io/kairos-sdk/unstructured"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"gopkg.in/yaml.v3"
@@ -1109,6 +1111,40 @@ local_key_2: local_value_2
 		})
 	})
 
+	Describe("ParseCmdLine", func() {
+		var cmdline string
+
+		BeforeEach(func() {
+			f, err := os.CreateTemp("", "cmdline")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Close()).To(Succeed())
+			cmdline = f.Name()
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cmdline)).To(Succeed())
+		})
+
+		It("merges the keys on top of the base64 encoded configs", func() {
+			first := base64.StdEncoding.EncodeToString([]byte("#cloud-config\nconfig_url: foo\noptions:\n  a: b\n"))
+			second := base64.RawStdEncoding.EncodeToString([]byte("options:\n  c: d\n"))
+			Expect(os.WriteFile(cmdline, []byte("config.b64="+first+" config.b64="+second+" options.a=cmdline"), os.ModePerm)).To(Succeed())
+
+			c, err := ParseCmdLine(cmdline, FilterKeysTestMerge)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(Equal(Config{
+				"config_url": "foo",
+				"options":    Config{"a": "cmdline", "c": "d"},
+			}))
+		})
+
+		It("fails on invalid base64", func() {
+			Expect(os.WriteFile(cmdline, []byte("config.b64=!!!"), os.ModePerm)).To(Succeed())
+			_, err := ParseCmdLine(cmdline, FilterKeysTestMerge)
+			Expect(err).To(MatchError(ContainSubstring("decoding config.b64")))
+		})
+	})
+
 	Describe("String", func() {
 		var conf *Config
 		BeforeEach(func() {
@@ -1176,6 +1212,31 @@ some:
 			Expect(err).ToNot(HaveOccurred())
 			Expect(v).To(Equal("false\n"))
 		})
+
+		It("can run jq expressions", func() {
+			o := &Options{}
+
+			err = o.Apply(MergeBootLine, Directories(tmpDir),
+				WithBootCMDLineFile(filepath.Join(tmpDir, "b")),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			c, err := Scan(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(c.Query(".some.other | keys")).To(Equal("- key\n"))
+			Expect(c.Query("some.other | keys")).To(Equal("- key\n"))
+			Expect(c.Query("some.other.key | tostring | length")).To(Equal("1\n"))
+			Expect(c.Query("")).To(ContainSubstring("other:\n"))
+			values, err := c.QueryValues(".some.other.key + $n", unstructured.QueryVariable("n", 1))
+			Expect(err).ToNot(HaveOccurred())
+			var n int
+			Expect(values.First().Decode(&n)).To(Succeed())
+			Expect(n).To(Equal(4))
+
+			_, err = c.Query(".some[")
+			Expect(err).To(HaveOccurred())
+		})
 	})
 })
 
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxConfigURLDepth is the maximum number of remote configs followed in a
+// config_url chain when no other limit is set.
+const DefaultMaxConfigURLDepth = 10
+
+var (
+	ErrConfigURLLoop     = errors.New("config_url loop detected")
+	ErrConfigURLDepth    = errors.New("maximum config_url depth exceeded")
+	ErrConfigURLChecksum = errors.New("config_url checksum mismatch")
+)
+
+// ConfigURLError is returned when a chain of config_url can't be followed. It
+// holds the URLs in the order they were followed, the failing one being the last.
+type ConfigURLError struct {
+	Chain []string
+	Err   error
+}
+
+func (e *ConfigURLError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err.Error(), strings.Join(e.Chain, " -> "))
+}
+
+func (e *ConfigURLError) Unwrap() error {
+	return e.Err
+}
+
+// configURLChain keeps track of the remote configs followed from a single config
+// to detect loops and limit its depth.
+type configURLChain struct {
+	state    *scanState
+	maxDepth int
+	urls     []string
+	digests  map[string]string
+}
+
+func newConfigURLChain(s *scanState) *configURLChain {
+	maxDepth := s.maxConfigURLDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxConfigURLDepth
+	}
+	return &configURLChain{state: s, maxDepth: maxDepth, digests: map[string]string{}}
+}
+
+func (c *configURLChain) fail(err error) error {
+	return &ConfigURLError{Chain: append([]string{}, c.urls...), Err: err}
+}
+
+// visit adds the url to the chain, failing if it was already visited or the
+// chain is too long.
+func (c *configURLChain) visit(url string) error {
+	for _, u := range c.urls {
+		if u == url {
+			c.urls = append(c.urls, url)
+			return c.fail(ErrConfigURLLoop)
+		}
+	}
+	c.urls = append(c.urls, url)
+	if len(c.urls) > c.maxDepth {
+		return c.fail(fmt.Errorf("%w (%d)", ErrConfigURLDepth, c.maxDepth))
+	}
+	return nil
+}
+
+// verify checks the content downloaded from the last visited url against the
+// expected sha256, if any, and fails if the same content was already seen
+// under a different url, as it would point to the same config_url again.
+func (c *configURLChain) verify(body []byte, expectedSHA256 string) error {
+	sum := sha256.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, digest) {
+		return c.fail(fmt.Errorf("%w: expected %s, got %s", ErrConfigURLChecksum, expectedSHA256, digest))
+	}
+
+	url := c.urls[len(c.urls)-1]
+	if previous, seen := c.digests[digest]; seen {
+		return c.fail(fmt.Errorf("%w: %s has the same content as %s", ErrConfigURLLoop, url, previous))
+	}
+	c.digests[digest] = url
+
+	return nil
+}
+
+// ConfigURLSHA256 returns the value of config_url_sha256 if set or empty string otherwise.
+func (c Config) ConfigURLSHA256() string {
+	if val, hasKey := c["config_url_sha256"]; hasKey {
+		if s, isString := val.(string); isString {
+			return s
+		}
+	}
+
+	return ""
+}
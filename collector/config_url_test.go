@@ -0,0 +1,101 @@
+package collector_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("config_url chains", func() {
+	var serverDir string
+	var closeFunc ServerCloseFunc
+	var port int
+	var err error
+
+	url := func(file string) string {
+		return fmt.Sprintf("http://127.0.0.1:%d/%s", port, file)
+	}
+	writeRemote := func(file, content string) {
+		Expect(os.WriteFile(filepath.Join(serverDir, file), []byte(content), os.ModePerm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		serverDir, err = os.MkdirTemp("", "config_url")
+		Expect(err).ToNot(HaveOccurred())
+		closeFunc, port, err = startAssetServer(serverDir)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		closeFunc()
+		Expect(os.RemoveAll(serverDir)).To(Succeed())
+	})
+
+	It("detects loops between urls", func() {
+		writeRemote("a.yaml", fmt.Sprintf("#cloud-config\nconfig_url: %s\n", url("b.yaml")))
+		writeRemote("b.yaml", fmt.Sprintf("#cloud-config\nconfig_url: %s\n", url("a.yaml")))
+
+		c := &Config{"config_url": url("a.yaml")}
+		err := c.MergeConfigURL()
+		Expect(errors.Is(err, ErrConfigURLLoop)).To(BeTrue())
+
+		var urlErr *ConfigURLError
+		Expect(errors.As(err, &urlErr)).To(BeTrue())
+		Expect(urlErr.Chain).To(Equal([]string{url("a.yaml"), url("b.yaml"), url("a.yaml")}))
+	})
+
+	It("detects loops between urls with the same content", func() {
+		writeRemote("a.yaml", fmt.Sprintf("#cloud-config\nconfig_url: %s?alias\n", url("a.yaml")))
+
+		c := &Config{"config_url": url("a.yaml")}
+		Expect(errors.Is(c.MergeConfigURL(), ErrConfigURLLoop)).To(BeTrue())
+	})
+
+	It("limits the depth of the chain", func() {
+		for i := 0; i < 5; i++ {
+			writeRemote(fmt.Sprintf("%d.yaml", i), fmt.Sprintf("#cloud-config\nconfig_url: %s\nkey_%d: value\n", url(fmt.Sprintf("%d.yaml", i+1)), i))
+		}
+		writeRemote("5.yaml", "#cloud-config\nlast: true\n")
+
+		dir, err := os.MkdirTemp("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(fmt.Sprintf("#cloud-config\nconfig_url: %s\n", url("0.yaml"))), os.ModePerm)).To(Succeed())
+
+		o := &Options{}
+		Expect(o.Apply(NoLogs, Directories(dir), MaxConfigURLDepth(3))).To(Succeed())
+		_, err = Scan(o, FilterKeysTest)
+		Expect(errors.Is(err, ErrConfigURLDepth)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring(url("3.yaml")))
+
+		o = &Options{}
+		Expect(o.Apply(NoLogs, Directories(dir))).To(Succeed())
+		c, err := Scan(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect((*c)["last"]).To(BeTrue())
+
+		Expect(o.Apply(MaxConfigURLDepth(-1))).To(MatchError("invalid max config_url depth -1"))
+	})
+
+	It("verifies the checksum of the remote config", func() {
+		content := "#cloud-config\nremote: true\n"
+		writeRemote("a.yaml", content)
+		sum := sha256.Sum256([]byte(content))
+
+		c := &Config{"config_url": url("a.yaml"), "config_url_sha256": hex.EncodeToString(sum[:])}
+		Expect(c.MergeConfigURL()).To(Succeed())
+		Expect((*c)["remote"]).To(BeTrue())
+
+		c = &Config{"config_url": url("a.yaml"), "config_url_sha256": fmt.Sprintf("%064d", 0)}
+		err := c.MergeConfigURL()
+		Expect(errors.Is(err, ErrConfigURLChecksum)).To(BeTrue())
+		Expect(*c).ToNot(HaveKey("remote"))
+	})
+})
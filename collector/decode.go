@@ -0,0 +1,299 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decode stores the Config in the struct pointed by target, e.g. a
+// schema.RootSchema or a clusterplugin.Config, and returns the keys of the
+// Config that have no field in it, like typos.
+//
+// Fields are matched by their yaml tag, json tag or, if they have none, by
+// their name ignoring the case. Fields of keys missing in the Config are set to
+// the value of their `default:"..."` tag, as the schema package does, unless
+// they were already set in target. Types with their own UnmarshalYAML are
+// decoded with it.
+func (c Config) Decode(target interface{}) (unknown []string, err error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, fmt.Errorf("decode target must be a non-nil pointer, got %T", target)
+	}
+
+	d := &decoder{}
+	d.decode("", map[string]interface{}(c), rv.Elem())
+	sort.Strings(d.unknown)
+	return d.unknown, errors.Join(d.errs...)
+}
+
+type decoder struct {
+	unknown []string
+	errs    []error
+}
+
+// structField is a field of a struct and the key it's decoded from.
+type structField struct {
+	key string
+	// index is nil for keys of the oneOf alternatives of a schema type, which
+	// are known but have no field to be decoded into.
+	index []int
+	// caseInsensitive is set for fields without tags, which match the key
+	// ignoring the case.
+	caseInsensitive bool
+	defaultValue    string
+	hasDefault      bool
+}
+
+func (d *decoder) fail(path string, err error) {
+	if path == "" {
+		d.errs = append(d.errs, err)
+		return
+	}
+	d.errs = append(d.errs, fmt.Errorf("%s: %w", path, err))
+}
+
+func (d *decoder) decode(path string, value interface{}, rv reflect.Value) {
+	if value == nil {
+		return
+	}
+
+	if rv.Kind() == reflect.Pointer && !hasCustomUnmarshal(rv.Type()) {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		d.decode(path, value, rv.Elem())
+		return
+	}
+
+	if !hasCustomUnmarshal(rv.Type()) {
+		switch rv.Kind() {
+		case reflect.Struct:
+			if m, ok := asMap(value); ok {
+				d.decodeStruct(path, m, rv)
+				return
+			}
+		case reflect.Map:
+			if m, ok := asMap(value); ok && rv.Type().Key().Kind() == reflect.String {
+				if rv.IsNil() {
+					rv.Set(reflect.MakeMap(rv.Type()))
+				}
+				for _, k := range sortedKeys(m) {
+					elem := reflect.New(rv.Type().Elem()).Elem()
+					d.decode(joinPath(path, k), m[k], elem)
+					rv.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), elem)
+				}
+				return
+			}
+		case reflect.Slice:
+			if list, ok := value.([]interface{}); ok {
+				slice := reflect.MakeSlice(rv.Type(), len(list), len(list))
+				for i, item := range list {
+					d.decode(fmt.Sprintf("%s[%d]", path, i), item, slice.Index(i))
+				}
+				rv.Set(slice)
+				return
+			}
+		case reflect.Interface:
+			if rv.NumMethod() == 0 {
+				rv.Set(reflect.ValueOf(plainValue(value)))
+				return
+			}
+		}
+	}
+
+	// Scalars, and anything that doesn't match the structure of the target,
+	// are left to yaml so its errors and conversions apply.
+	dat, err := yaml.Marshal(plainValue(value))
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	if err := yaml.Unmarshal(dat, rv.Addr().Interface()); err != nil {
+		d.fail(path, err)
+	}
+}
+
+func (d *decoder) decodeStruct(path string, m map[string]interface{}, rv reflect.Value) {
+	fields := structFields(rv.Type())
+	used := map[string]bool{MergeDirectivesKey: true}
+
+	for _, f := range fields {
+		key, value, found := lookupKey(m, f)
+		if !found {
+			if f.index != nil {
+				d.applyDefaults(joinPath(path, f.key), f, rv.FieldByIndex(f.index))
+			}
+			continue
+		}
+		used[key] = true
+		if f.index != nil {
+			d.decode(joinPath(path, key), value, rv.FieldByIndex(f.index))
+		}
+	}
+
+	for _, k := range sortedKeys(m) {
+		if !used[k] {
+			d.unknown = append(d.unknown, joinPath(path, k))
+		}
+	}
+}
+
+// applyDefaults sets the default of a field missing in the Config and the
+// defaults of the fields of nested structs, unless they are already set.
+func (d *decoder) applyDefaults(path string, f structField, field reflect.Value) {
+	if f.hasDefault && field.IsZero() {
+		if err := yaml.Unmarshal([]byte(f.defaultValue), field.Addr().Interface()); err != nil {
+			d.fail(path, fmt.Errorf("invalid default %q: %w", f.defaultValue, err))
+		}
+		return
+	}
+	if field.Kind() == reflect.Struct && !hasCustomUnmarshal(field.Type()) {
+		for _, nested := range structFields(field.Type()) {
+			if nested.index == nil {
+				continue
+			}
+			d.applyDefaults(joinPath(path, nested.key), nested, field.FieldByIndex(nested.index))
+		}
+	}
+}
+
+// oneOfExposer is implemented by the schema types that must match one of
+// several alternatives, see schema.OneOfModel.
+type oneOfExposer interface {
+	JSONSchemaOneOf() []interface{}
+}
+
+// structFields returns the fields of the struct type, including the ones of
+// inlined and embedded untagged structs and the keys of their oneOf
+// alternatives.
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+	if exposer, ok := reflect.Zero(t).Interface().(oneOfExposer); ok {
+		for _, alternative := range exposer.JSONSchemaOneOf() {
+			for _, f := range structFields(reflect.TypeOf(alternative)) {
+				fields = append(fields, structField{key: f.key, caseInsensitive: f.caseInsensitive})
+			}
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, inline, skip := fieldName(sf)
+		if skip {
+			continue
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if (inline || (sf.Anonymous && name == "")) && ft.Kind() == reflect.Struct && sf.Type.Kind() == reflect.Struct {
+			for _, nested := range structFields(ft) {
+				if nested.index != nil {
+					nested.index = append([]int{i}, nested.index...)
+				}
+				fields = append(fields, nested)
+			}
+			continue
+		}
+
+		f := structField{key: name, index: []int{i}}
+		if name == "" {
+			f.key = strings.ToLower(sf.Name)
+			f.caseInsensitive = true
+		}
+		f.defaultValue, f.hasDefault = sf.Tag.Lookup("default")
+		if f.defaultValue == "" {
+			f.hasDefault = false
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// fieldName returns the name of the field in its yaml or json tag, if it has
+// to be inlined or if it has to be skipped.
+func fieldName(sf reflect.StructField) (name string, inline, skip bool) {
+	for _, tagName := range []string{"yaml", "json"} {
+		tag, ok := sf.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		if tag == "-" {
+			return "", false, true
+		}
+		parts := strings.Split(tag, ",")
+		for _, opt := range parts[1:] {
+			if opt == "inline" {
+				inline = true
+			}
+		}
+		if parts[0] != "" || inline {
+			return parts[0], inline, false
+		}
+	}
+	return "", false, false
+}
+
+func lookupKey(m map[string]interface{}, f structField) (string, interface{}, bool) {
+	if v, ok := m[f.key]; ok {
+		return f.key, v, true
+	}
+	if f.caseInsensitive {
+		for k, v := range m {
+			if strings.EqualFold(k, f.key) {
+				return k, v, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// hasCustomUnmarshal returns true if the type decodes itself from YAML or
+// text, so it must not be decoded field by field.
+func hasCustomUnmarshal(t reflect.Type) bool {
+	pt := t
+	if t.Kind() != reflect.Pointer {
+		pt = reflect.PointerTo(t)
+	}
+	for _, method := range []string{"UnmarshalYAML", "UnmarshalText"} {
+		if _, ok := pt.MethodByName(method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch t := v.(type) {
+	case Config:
+		return t, true
+	case map[string]interface{}:
+		return t, true
+	}
+	return nil, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,119 @@
+package collector_test
+
+import (
+	"github.com/kairos-io/kairos-sdk/clusterplugin"
+	. "github.com/kairos-io/kairos-sdk/collector"
+	"github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Decode", func() {
+	parse := func(content string) Config {
+		c := Config{}
+		Expect(yaml.Unmarshal([]byte(content), &c)).To(Succeed())
+		return c
+	}
+
+	It("decodes into the schema and applies its defaults", func() {
+		c := parse(`#cloud-config
+config_url: https://example.com/config.yaml
+cosign-key: /etc/cosign.pub
+grub_options:
+  extra_cmdline: console=tty0
+install:
+  device: /dev/sda
+  bind_mounts:
+  - /var/lib/ca
+users:
+- name: kairos
+  passwd: kairos
+p2p:
+  network_token: token
+platform:
+  arch: amd64
+`)
+		root := &schema.RootSchema{}
+		unknown, err := c.Decode(root)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unknown).To(BeEmpty())
+
+		Expect(root.ConfigURL).To(Equal("https://example.com/config.yaml"))
+		Expect(root.CosignPubKey).To(Equal("/etc/cosign.pub"))
+		Expect(root.GrubOptionsSchema.ExtraCmdline).To(Equal("console=tty0"))
+		Expect(root.Install.Device).To(Equal("/dev/sda"))
+		Expect(root.Install.BindMounts).To(Equal([]string{"/var/lib/ca"}))
+		Expect(root.Users).To(HaveLen(1))
+		Expect(root.Users[0].Name).To(Equal("kairos"))
+		Expect(root.Platform.Arch).To(Equal("amd64"))
+
+		By("setting the defaults of the missing keys")
+		Expect(root.P2P.Role).To(Equal("none"))
+		Expect(root.P2P.DisableDHT).To(BeTrue())
+		Expect(root.P2P.VPN.Create).To(BeTrue())
+	})
+
+	It("reports unknown keys", func() {
+		c := parse(`#cloud-config
+hostnam: foo
+install:
+  devise: /dev/sda
+users:
+- name: kairos
+  pasword: kairos
+$merge:
+  users: replace
+`)
+		unknown, err := c.Decode(&schema.RootSchema{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unknown).To(Equal([]string{"hostnam", "install.devise", "users[0].pasword"}))
+	})
+
+	It("decodes with the yaml tags and unmarshallers of the target", func() {
+		c := parse(`#cloud-config
+cluster:
+  cluster_token: token
+  role: init
+  cluster_config_path: /etc/cluster.yaml
+  providerConfig:
+    foo: bar
+install:
+  device: /dev/sda
+`)
+		config := &clusterplugin.Config{}
+		unknown, err := c.Decode(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unknown).To(Equal([]string{"install"}))
+		Expect(config.Cluster.ClusterToken).To(Equal("token"))
+		Expect(config.Cluster.Role).To(Equal(clusterplugin.Role(clusterplugin.RoleInit)))
+		Expect(config.Cluster.ClusterConfigPath).To(Equal("/etc/cluster.yaml"))
+		Expect(config.Cluster.ProviderOptions).To(Equal(map[string]string{"foo": "bar"}))
+	})
+
+	It("keeps the values already set and reports type errors", func() {
+		type provider struct {
+			Name    string            `yaml:"name" default:"k3s"`
+			Port    int               `yaml:"port" default:"6443"`
+			Args    []string          `yaml:"args"`
+			Labels  map[string]string `yaml:"labels"`
+			Options interface{}       `yaml:"options"`
+		}
+		target := &provider{Port: 8443}
+		_, err := parse("name: k0s\nargs: [a, b]\noptions: {foo: [1]}\n").Decode(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*target).To(Equal(provider{
+			Name:    "k0s",
+			Port:    8443,
+			Args:    []string{"a", "b"},
+			Options: map[string]interface{}{"foo": []interface{}{1}},
+		}))
+
+		_, err = parse("port: nope\nlabels:\n  a: [1]\n").Decode(target)
+		Expect(err).To(MatchError(ContainSubstring("port:")))
+		Expect(err).To(MatchError(ContainSubstring("labels.a:")))
+
+		_, err = parse("port: 1").Decode(provider{})
+		Expect(err).To(HaveOccurred())
+	})
+})
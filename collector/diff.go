@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// KeyChange is a key whose value differs between two configs. Old is nil if
+// the key was added and New is nil if it was removed.
+type KeyChange struct {
+	Path string      `yaml:"path" json:"path"`
+	Old  interface{} `yaml:"old,omitempty" json:"old,omitempty"`
+	New  interface{} `yaml:"new,omitempty" json:"new,omitempty"`
+}
+
+// ConfigDiff holds the leaf keys that differ between two configs, sorted by
+// path. As in Provenance, lists are compared as a whole.
+type ConfigDiff struct {
+	Added   []KeyChange `yaml:"added,omitempty" json:"added,omitempty"`
+	Removed []KeyChange `yaml:"removed,omitempty" json:"removed,omitempty"`
+	Changed []KeyChange `yaml:"changed,omitempty" json:"changed,omitempty"`
+}
+
+// Diff returns the keys that were added, removed or changed from a to b. A nil
+// Config is the same as an empty one.
+func Diff(a, b *Config) *ConfigDiff {
+	old, current := map[string]interface{}{}, map[string]interface{}{}
+	if a != nil {
+		leafValues("", *a, old)
+	}
+	if b != nil {
+		leafValues("", *b, current)
+	}
+
+	d := &ConfigDiff{}
+	for path, v := range current {
+		o, found := old[path]
+		switch {
+		case !found:
+			d.Added = append(d.Added, KeyChange{Path: path, New: v})
+		case !reflect.DeepEqual(plainValue(o), plainValue(v)):
+			d.Changed = append(d.Changed, KeyChange{Path: path, Old: o, New: v})
+		}
+	}
+	for path, o := range old {
+		if _, found := current[path]; !found {
+			d.Removed = append(d.Removed, KeyChange{Path: path, Old: o})
+		}
+	}
+
+	for _, changes := range [][]KeyChange{d.Added, d.Removed, d.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	}
+	return d
+}
+
+// Empty returns true if both configs are equal.
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String renders the diff as text, one key per line prefixed by "+" if it was
+// added, "-" if it was removed or "~" if it was changed.
+func (d *ConfigDiff) String() string {
+	lines := []string{}
+	for _, c := range d.Added {
+		lines = append(lines, fmt.Sprintf("+ %s: %s", c.Path, jsonValue(c.New)))
+	}
+	for _, c := range d.Removed {
+		lines = append(lines, fmt.Sprintf("- %s: %s", c.Path, jsonValue(c.Old)))
+	}
+	for _, c := range d.Changed {
+		lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", c.Path, jsonValue(c.Old), jsonValue(c.New)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// JSON renders the diff as JSON.
+func (d *ConfigDiff) JSON() (string, error) {
+	dat, err := json.Marshal(d)
+	return string(dat), err
+}
+
+// jsonValue renders a value in JSON, so strings are quoted and lists or maps
+// fit in a line.
+func jsonValue(v interface{}) string {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(dat)
+}
+
+// leafValues collects the value of every leaf key of the map by its dot
+// separated path.
+func leafValues(prefix string, m map[string]interface{}, values map[string]interface{}) {
+	for k, v := range m {
+		if k == MergeDirectivesKey {
+			continue
+		}
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		var nested map[string]interface{}
+		switch t := v.(type) {
+		case Config:
+			nested = t
+		case map[string]interface{}:
+			nested = t
+		}
+
+		if len(nested) == 0 {
+			values[path] = v
+			continue
+		}
+		leafValues(path, nested, values)
+	}
+}
+
+// plainValue returns a copy of v with all the Configs turned into plain maps,
+// so values can be compared regardless of how they were built.
+func plainValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case Config:
+		return plainValue(map[string]interface{}(t))
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			result[k] = plainValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(t))
+		for i, item := range t {
+			result[i] = plainValue(item)
+		}
+		return result
+	}
+	return v
+}
@@ -0,0 +1,74 @@
+package collector_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Diff", func() {
+	parse := func(s string) *Config {
+		c := &Config{}
+		Expect(yaml.Unmarshal([]byte(s), c)).To(Succeed())
+		return c
+	}
+
+	a := `
+hostname: foo
+install:
+  device: /dev/sda
+  auto: true
+users:
+- name: kairos
+`
+	b := `
+hostname: bar
+install:
+  device: /dev/sda
+  reboot: true
+users:
+- name: kairos
+- name: admin
+k3s:
+  enabled: true
+`
+
+	It("returns the added, removed and changed keys", func() {
+		d := Diff(parse(a), parse(b))
+		Expect(d.Empty()).To(BeFalse())
+		Expect(d.Added).To(Equal([]KeyChange{
+			{Path: "install.reboot", New: true},
+			{Path: "k3s.enabled", New: true},
+		}))
+		Expect(d.Removed).To(Equal([]KeyChange{
+			{Path: "install.auto", Old: true},
+		}))
+		Expect(d.Changed).To(HaveLen(2))
+		Expect(d.Changed[0]).To(Equal(KeyChange{Path: "hostname", Old: "foo", New: "bar"}))
+		Expect(d.Changed[1].Path).To(Equal("users"))
+	})
+
+	It("is empty for equal configs", func() {
+		Expect(Diff(parse(a), parse(a)).Empty()).To(BeTrue())
+		Expect(Diff(nil, &Config{}).Empty()).To(BeTrue())
+		Expect(Diff(&Config{"foo": Config{"bar": "baz"}}, &Config{"foo": map[string]interface{}{"bar": "baz"}}).Empty()).To(BeTrue())
+	})
+
+	It("renders as text", func() {
+		Expect(Diff(parse(a), parse(b)).String()).To(Equal(`+ install.reboot: true
++ k3s.enabled: true
+- install.auto: true
+~ hostname: "foo" -> "bar"
+~ users: [{"name":"kairos"}] -> [{"name":"kairos"},{"name":"admin"}]`))
+	})
+
+	It("renders as JSON", func() {
+		out, err := Diff(parse("foo: bar"), parse("foo: baz\nnew: 1")).JSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(MatchJSON(`{
+			"added": [{"path": "new", "new": 1}],
+			"changed": [{"path": "foo", "old": "bar", "new": "baz"}]
+		}`))
+	})
+})
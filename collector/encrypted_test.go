@@ -0,0 +1,152 @@
+package collector_test
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// indent indents every line of s so it can be embedded as a YAML block scalar.
+func indent(s string, spaces int) string {
+	prefix := strings.Repeat(" ", spaces)
+	return prefix + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n"+prefix)
+}
+
+func ageEncrypt(recipient age.Recipient, plaintext string) string {
+	buf := &bytes.Buffer{}
+	a := armor.NewWriter(buf)
+	w, err := age.Encrypt(a, recipient)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = io.WriteString(w, plaintext)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(w.Close()).To(Succeed())
+	Expect(a.Close()).To(Succeed())
+	return buf.String()
+}
+
+var _ = Describe("Encrypted sections", func() {
+	var tmpDir, keyFile string
+	var identity *age.X25519Identity
+	var err error
+
+	scan := func(opts ...Option) (*Config, *ScanReport) {
+		o := &Options{}
+		Expect(o.Apply(append([]Option{NoLogs, Directories(tmpDir)}, opts...)...)).To(Succeed())
+		c, report, err := ScanWithReport(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		return c, report
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "encrypted")
+		Expect(err).ToNot(HaveOccurred())
+
+		identity, err = age.GenerateX25519Identity()
+		Expect(err).ToNot(HaveOccurred())
+		keyFile = filepath.Join(tmpDir, "config.key")
+		Expect(os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("decrypts age sections with the node key", func() {
+		secret := ageEncrypt(identity.Recipient(), "network_token: s3cr3t\nauto:\n  enable: true\n")
+		Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte(`#cloud-config
+p2p:
+  network_id: id
+  auto:
+    ha:
+      enable: false
+  encrypted: |
+`+indent(secret, 4)+"\n"), os.ModePerm)).To(Succeed())
+
+		c, _ := scan(DecryptionKeys(keyFile))
+		Expect((*c)["p2p"]).To(Equal(Config{
+			"network_id":    "id",
+			"network_token": "s3cr3t",
+			"auto": Config{
+				"enable": true,
+				"ha":     Config{"enable": false},
+			},
+		}))
+	})
+
+	It("decrypts OpenPGP sections", func() {
+		config := &packet.Config{DefaultHash: crypto.SHA256}
+		entity, err := openpgp.NewEntity("kairos", "", "kairos@example.com", config)
+		Expect(err).ToNot(HaveOccurred())
+
+		key := &bytes.Buffer{}
+		w, err := pgparmor.Encode(key, openpgp.PrivateKeyType, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entity.SerializePrivate(w, nil)).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+		Expect(os.WriteFile(keyFile, key.Bytes(), 0600)).To(Succeed())
+
+		message := &bytes.Buffer{}
+		a, err := pgparmor.Encode(message, "PGP MESSAGE", nil)
+		Expect(err).ToNot(HaveOccurred())
+		p, err := openpgp.Encrypt(a, []*openpgp.Entity{entity}, nil, nil, config)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = io.WriteString(p, "hostname: secret-host\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.Close()).To(Succeed())
+		Expect(a.Close()).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\nencrypted: |\n"+indent(message.String(), 2)+"\n"), os.ModePerm)).To(Succeed())
+
+		c, _ := scan(DecryptionKeys(keyFile))
+		Expect(*c).To(Equal(Config{"hostname": "secret-host"}))
+	})
+
+	It("keeps values of encrypted keys which aren't encrypted messages", func() {
+		Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte(`#cloud-config
+install:
+  encrypted: true
+storage:
+  encrypted:
+    cipher: aes-xts-plain64
+`), os.ModePerm)).To(Succeed())
+
+		c, report := scan()
+		Expect(*c).To(Equal(Config{
+			"install": Config{"encrypted": true},
+			"storage": Config{"encrypted": Config{"cipher": "aes-xts-plain64"}},
+		}))
+		Expect(report.Skipped).ToNot(ContainElement(HaveField("Source.Location", HaveSuffix("config.yaml"))))
+
+		Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\nencrypted: not a secret\n"), os.ModePerm)).To(Succeed())
+		c, _ = scan()
+		Expect(*c).To(Equal(Config{"encrypted": "not a secret"}))
+	})
+
+	It("skips configs that can't be decrypted", func() {
+		other, err := age.GenerateX25519Identity()
+		Expect(err).ToNot(HaveOccurred())
+		secret := ageEncrypt(other.Recipient(), "hostname: foo\n")
+		Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\nencrypted: |\n"+indent(secret, 2)+"\n"), os.ModePerm)).To(Succeed())
+
+		c, report := scan(DecryptionKeys(keyFile))
+		Expect(*c).To(BeEmpty())
+		Expect(report.Skipped).To(ContainElement(HaveField("Reason", HavePrefix("couldn't decrypt:"))))
+
+		By("ignoring missing default keys")
+		c, report = scan()
+		Expect(*c).To(BeEmpty())
+		Expect(report.Skipped).To(ContainElement(HaveField("Reason", ContainSubstring("no age identity found"))))
+	})
+})
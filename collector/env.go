@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/kairos-io/kairos-sdk/unstructured"
+	"gopkg.in/yaml.v3"
+)
+
+// envKeySeparator separates the levels of a key in an environment variable
+// name, e.g. KAIROS_INSTALL__DEVICE is install.device.
+const envKeySeparator = "__"
+
+// envToDot returns the variables of environ whose name starts with prefix as
+// keys in dot notation, with the prefix removed and in lower case.
+func envToDot(prefix string, environ []string) map[string]interface{} {
+	v := map[string]interface{}{}
+	for _, item := range environ {
+		name, value, found := strings.Cut(item, "=")
+		if !found || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(name, prefix)
+		if key == "" {
+			continue
+		}
+		key = strings.ToLower(strings.ReplaceAll(key, envKeySeparator, "."))
+		v[key] = value
+	}
+	return v
+}
+
+// ParseEnv reads options from the environment variables in environ that start
+// with prefix and returns the equivalent Config. Levels are separated by a
+// double underscore, so KAIROS_INSTALL__DEVICE=/dev/sda with prefix "KAIROS_"
+// becomes install.device: /dev/sda.
+func ParseEnv(prefix string, environ []string, filter func(d []byte) ([]byte, error)) (*Config, error) {
+	result := Config{}
+	v := envToDot(prefix, environ)
+	if len(v) == 0 {
+		return &result, nil
+	}
+
+	dotToYAML, err := unstructured.ToYAML(v)
+	if err != nil {
+		return &result, err
+	}
+
+	filteredYAML, err := filter(dotToYAML)
+	if err != nil {
+		return &result, err
+	}
+
+	err = yaml.Unmarshal(filteredYAML, &result)
+	if err != nil {
+		return &result, err
+	}
+
+	return &result, nil
+}
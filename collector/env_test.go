@@ -0,0 +1,96 @@
+package collector_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Environment", func() {
+	Describe("ParseEnv", func() {
+		It("turns prefixed variables into nested keys", func() {
+			c, err := ParseEnv("KAIROS_", []string{
+				"KAIROS_INSTALL__DEVICE=/dev/sda",
+				"KAIROS_INSTALL__AUTO=true",
+				"KAIROS_CONFIG_URL=https://example.com/config.yaml",
+				"KAIROS_STAGES__INITRAMFS__NAME=a=b",
+				"PATH=/usr/bin",
+				"KAIROS_=ignored",
+			}, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(Equal(Config{
+				"install": Config{
+					"device": "/dev/sda",
+					"auto":   true,
+				},
+				"config_url": "https://example.com/config.yaml",
+				"stages": Config{
+					"initramfs": Config{"name": "a=b"},
+				},
+			}))
+		})
+
+		It("returns an empty config without matching variables", func() {
+			c, err := ParseEnv("KAIROS_", []string{"PATH=/usr/bin"}, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(BeEmpty())
+		})
+	})
+
+	Describe("Scan", func() {
+		var tmpDir string
+		var err error
+
+		BeforeEach(func() {
+			tmpDir, err = os.MkdirTemp("", "env")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\ninstall:\n  device: /dev/vda\n  auto: true\n"), os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("KAIROSTEST_INSTALL__DEVICE", "/dev/sda")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.Unsetenv("KAIROSTEST_INSTALL__DEVICE")).To(Succeed())
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		It("merges the environment over the files", func() {
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir), Environment("KAIROSTEST_"))).To(Succeed())
+
+			c, p, err := ScanWithProvenance(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*c)["install"]).To(Equal(Config{"device": "/dev/sda", "auto": true}))
+
+			kp, err := p.Query("install.device")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kp.Source).To(Equal(Source{Kind: SourceEnv, Location: "KAIROSTEST_"}))
+			Expect(kp.Overrides).To(Equal([]Source{{Kind: SourceFile, Location: filepath.Join(tmpDir, "config.yaml")}}))
+		})
+
+		It("keeps the keys as they are without a filter", func() {
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir), Environment("KAIROSTEST_"))).To(Succeed())
+
+			c, err := Scan(o, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*c)["install"]).To(Equal(Config{"device": "/dev/sda", "auto": true}))
+		})
+
+		It("ignores the environment unless enabled", func() {
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir))).To(Succeed())
+
+			c, err := Scan(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*c)["install"]).To(HaveKeyWithValue("device", "/dev/vda"))
+		})
+
+		It("requires a prefix", func() {
+			o := &Options{}
+			Expect(o.Apply(Environment(""))).ToNot(Succeed())
+		})
+	})
+})
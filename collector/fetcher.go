@@ -0,0 +1,302 @@
+package collector
+
+import (
+	"archive/tar"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go"
+	registrytypes "github.com/docker/docker/api/types/registry"
+	"github.com/kairos-io/kairos-sdk/utils"
+)
+
+// Fetcher downloads the raw content of a remote config given its URL.
+type Fetcher interface {
+	Fetch(url string) ([]byte, error)
+}
+
+// FetcherFunc allows using a plain function as a Fetcher.
+type FetcherFunc func(url string) ([]byte, error)
+
+func (f FetcherFunc) Fetch(url string) ([]byte, error) {
+	return f(url)
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]Fetcher{
+		"http":  &HTTPFetcher{},
+		"https": &HTTPFetcher{},
+		"file":  FileFetcher{},
+		"data":  DataFetcher{},
+		"oci":   &OCIFetcher{},
+		"s3":    &S3Fetcher{},
+	}
+)
+
+// RegisterFetcher sets the Fetcher used to download config_url values with the
+// given scheme (e.g. "https"), replacing the previous one if any. This can be
+// used to configure the default fetchers (e.g. to add headers to HTTP requests)
+// or to support new schemes. The registered fetchers are shared by all the
+// scans in the process, use WithFetcher to set the ones of a single scan.
+func RegisterFetcher(scheme string, f Fetcher) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[strings.ToLower(scheme)] = f
+}
+
+// fetcherFor returns the Fetcher for the scheme of the given URL, from
+// overrides if it's there or from the registered ones otherwise.
+func fetcherFor(u string, overrides map[string]Fetcher) (Fetcher, error) {
+	scheme, _, found := strings.Cut(u, ":")
+	if !found {
+		return nil, fmt.Errorf("no scheme found in url %q", u)
+	}
+	scheme = strings.ToLower(scheme)
+	if f, ok := overrides[scheme]; ok {
+		return f, nil
+	}
+
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	f, ok := fetchers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme %q", scheme)
+	}
+	return f, nil
+}
+
+// fetch downloads the url with the Fetcher for its scheme, see fetcherFor.
+func fetch(u string, overrides map[string]Fetcher) ([]byte, error) {
+	f, err := fetcherFor(u, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return f.Fetch(u)
+}
+
+// HTTPFetcher downloads configs over http and https.
+type HTTPFetcher struct {
+	// Headers are added to every request.
+	Headers map[string]string
+	// BearerToken, if set, is sent in the Authorization header.
+	BearerToken string
+	// CABundle is a PEM encoded list of certificates trusted on top of the system ones.
+	CABundle []byte
+	// Attempts is the number of times the download is tried. Defaults to 3.
+	Attempts uint
+	// Delay is the time to wait between attempts. Defaults to 1 second.
+	Delay time.Duration
+	// Client is used to run the requests instead of a client built from the fields above.
+	Client *http.Client
+}
+
+func (f *HTTPFetcher) client() (*http.Client, error) {
+	if f.Client != nil {
+		return f.Client, nil
+	}
+	if len(f.CABundle) == 0 {
+		return http.DefaultClient, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(f.CABundle) {
+		return nil, errors.New("no valid certificates found in the CA bundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func (f *HTTPFetcher) Fetch(u string) ([]byte, error) {
+	return f.fetch(u, nil)
+}
+
+// fetch downloads the url, calling sign, if not nil, on every request right
+// before sending it.
+func (f *HTTPFetcher) fetch(u string, sign func(req *http.Request) error) ([]byte, error) {
+	var body []byte
+
+	client, err := f.client()
+	if err != nil {
+		return body, err
+	}
+
+	attempts := f.Attempts
+	if attempts == 0 {
+		attempts = 3
+	}
+	delay := f.Delay
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	err = retry.Do(
+		func() error {
+			req, err := http.NewRequest(http.MethodGet, u, nil)
+			if err != nil {
+				return err
+			}
+			for k, v := range f.Headers {
+				req.Header.Set(k, v)
+			}
+			if f.BearerToken != "" {
+				req.Header.Set("Authorization", "Bearer "+f.BearerToken)
+			}
+			if sign != nil {
+				if err := sign(req); err != nil {
+					return retry.Unrecoverable(err)
+				}
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err := fmt.Errorf("unexpected status: %d", resp.StatusCode)
+				// Client errors, like a missing signature, won't change by
+				// retrying, except timeouts and rate limits.
+				if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+					resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+					return retry.Unrecoverable(err)
+				}
+				return err
+			}
+
+			body, err = io.ReadAll(resp.Body)
+			return err
+		}, retry.Delay(delay), retry.Attempts(attempts),
+	)
+
+	return body, err
+}
+
+// FileFetcher reads configs from file:// URLs.
+type FileFetcher struct{}
+
+func (FileFetcher) Fetch(u string) ([]byte, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(parsed.Path)
+}
+
+// DataFetcher decodes configs inlined as data: URIs, either base64 or percent encoded
+// (e.g. "data:;base64,I2Nsb3VkLWNvbmZpZwo=").
+type DataFetcher struct{}
+
+func (DataFetcher) Fetch(u string) ([]byte, error) {
+	meta, data, found := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !found {
+		return nil, errors.New("invalid data URI, missing ','")
+	}
+
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	unescaped, err := url.PathUnescape(data)
+	return []byte(unescaped), err
+}
+
+// OCIFetcher downloads configs stored in OCI images or artifacts referenced as
+// oci://REGISTRY/REPOSITORY:TAG. Artifact layers which are not tarballs are
+// returned as they are, otherwise File is looked up in the image layers.
+type OCIFetcher struct {
+	// Platform of the image to pull. Defaults to the current one.
+	Platform string
+	// Auth for the registry. If nil, the default keychain is used.
+	Auth *registrytypes.AuthConfig
+	// Transport for the registry requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// File is the path of the config inside the image. Defaults to "cloud-config.yaml".
+	File string
+}
+
+func (f *OCIFetcher) Fetch(u string) ([]byte, error) {
+	img, err := utils.GetImage(strings.TrimPrefix(u, "oci://"), f.Platform, f.Auth, f.Transport)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	file := f.File
+	if file == "" {
+		file = "cloud-config.yaml"
+	}
+
+	var result []byte
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+
+		if !mediaType.IsLayer() {
+			rc, err := layer.Compressed()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, err
+		}
+		content, err := findInTar(rc, file)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Keep looking, upper layers take precedence
+		if content != nil {
+			result = content
+		}
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("%s not found in %s", file, u)
+	}
+	return result, nil
+}
+
+// findInTar returns the content of the file in the tar stream or nil if it isn't there.
+func findInTar(r io.Reader, file string) ([]byte, error) {
+	file = path.Clean("/" + file)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag == tar.TypeReg && path.Clean("/"+h.Name) == file {
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultMaxFileSize is the size, in bytes, of the biggest config file read
+// when Options.MaxFileSize is not set.
+const DefaultMaxFileSize int64 = 1024 * 1024
+
+// FileOrder is the order the config files found in the directories are
+// merged in. Files merged later override the values of the previous ones.
+type FileOrder string
+
+const (
+	// FileOrderDirectory merges the files of every directory in the order the
+	// directories are given, so the last directory has priority. Files of a
+	// directory, and its subdirectories, are merged in lexical order of their
+	// path.
+	FileOrderDirectory FileOrder = ""
+	// FileOrderBasename merges the files of all the directories in lexical
+	// order of their name, regardless of the directory they are in. Files with
+	// the same name are merged in directory order.
+	FileOrderBasename FileOrder = "basename"
+	// FileOrderNumericPrefix merges the files with a numeric prefix, like
+	// 10-network.yaml, in numeric order, so 9-foo.yaml goes before
+	// 10-bar.yaml, and then the ones without it in lexical order of their
+	// name.
+	FileOrderNumericPrefix FileOrder = "numeric"
+)
+
+func (o FileOrder) validate() error {
+	switch o {
+	case FileOrderDirectory, FileOrderBasename, FileOrderNumericPrefix:
+		return nil
+	}
+	return fmt.Errorf("unknown file order %q", o)
+}
+
+var numericPrefix = regexp.MustCompile(`^(\d+)`)
+
+// configFile is a file found in one of the scanned directories.
+type configFile struct {
+	path string
+	// dir is the index of the directory the file was found in.
+	dir int
+}
+
+// numericPrefix returns the number the name of the file starts with, if any.
+func (f configFile) numericPrefix() (uint64, bool) {
+	match := numericPrefix.FindString(filepath.Base(f.path))
+	if match == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(match, 10, 64)
+	return n, err == nil
+}
+
+// listConfigFiles returns the files in the directories that are not filtered
+// out, in the order they have to be merged, and records it in the report.
+func (s *scanState) listConfigFiles(dirs []string) []string {
+	files := []configFile{}
+	for i, dir := range dirs {
+		for _, f := range s.walk(dir) {
+			files = append(files, configFile{path: f, dir: i})
+		}
+	}
+
+	switch s.fileOrder {
+	case FileOrderBasename:
+		sort.SliceStable(files, func(i, j int) bool {
+			return filepath.Base(files[i].path) < filepath.Base(files[j].path)
+		})
+	case FileOrderNumericPrefix:
+		sort.SliceStable(files, func(i, j int) bool {
+			ni, oki := files[i].numericPrefix()
+			nj, okj := files[j].numericPrefix()
+			if oki != okj {
+				return oki
+			}
+			if ni != nj {
+				return ni < nj
+			}
+			return filepath.Base(files[i].path) < filepath.Base(files[j].path)
+		})
+	}
+
+	result := make([]string, len(files))
+	for i, f := range files {
+		result[i] = f.path
+	}
+	s.report.Files = append(s.report.Files, result...)
+	return result
+}
+
+// walk returns the files in dir in lexical order, up to the maximum depth,
+// skipping the ones not matching the include and exclude globs. If dir is a
+// file, it's the only one returned, unless it's filtered out by its name.
+func (s *scanState) walk(dir string) []string {
+	files := []string{}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		if rel == "." {
+			if d.IsDir() {
+				return nil
+			}
+			rel = filepath.Base(path)
+		}
+		depth := strings.Count(rel, string(filepath.Separator)) + 1
+
+		if d.IsDir() {
+			if s.maxDirectoryDepth > 0 && depth >= s.maxDirectoryDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !s.included(rel) {
+			s.skip(Source{Kind: SourceFile, Location: path}, zerolog.DebugLevel, "excluded")
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files
+}
+
+// included returns true if the file, relative to the scanned directory,
+// matches one of the include globs, if any, and none of the exclude ones.
+// Globs match either the name of the file or its relative path.
+func (s *scanState) included(rel string) bool {
+	if matchesAny(s.excludeFiles, rel) {
+		return false
+	}
+	return len(s.includeFiles) == 0 || matchesAny(s.includeFiles, rel)
+}
+
+func matchesAny(globs []string, rel string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, filepath.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSize returns the size in the biggest unit it's a multiple of.
+func formatSize(bytes int64) string {
+	switch {
+	case bytes > 0 && bytes%(1024*1024) == 0:
+		return fmt.Sprintf("%dMB", bytes/(1024*1024))
+	case bytes > 0 && bytes%1024 == 0:
+		return fmt.Sprintf("%dKB", bytes/1024)
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
@@ -0,0 +1,177 @@
+package collector_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config files", func() {
+	var dirA, dirB string
+	var err error
+
+	write := func(dir, name, content string) string {
+		f := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(f), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(f, []byte("#cloud-config\n"+content), os.ModePerm)).To(Succeed())
+		return f
+	}
+	scan := func(opts ...Option) (*Config, *ScanReport) {
+		o := &Options{}
+		Expect(o.Apply(append([]Option{NoLogs, Directories(dirA, dirB)}, opts...)...)).To(Succeed())
+		c, report, err := ScanWithReport(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		return c, report
+	}
+
+	BeforeEach(func() {
+		dirA, err = os.MkdirTemp("", "files_a")
+		Expect(err).ToNot(HaveOccurred())
+		dirB, err = os.MkdirTemp("", "files_b")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dirA)).To(Succeed())
+		Expect(os.RemoveAll(dirB)).To(Succeed())
+	})
+
+	Describe("size limit", func() {
+		It("skips files bigger than the limit in bytes", func() {
+			// Up to 2MB used to pass the 1MB limit.
+			big := write(dirA, "big.yaml", "foo: "+strings.Repeat("a", 1024*1024+512*1024)+"\n")
+			write(dirA, "small.yaml", "bar: "+strings.Repeat("b", 2048)+"\n")
+
+			c, report := scan()
+			Expect(*c).To(HaveKey("bar"))
+			Expect(*c).ToNot(HaveKey("foo"))
+			Expect(report.Skipped).To(ContainElement(SkippedSource{
+				Source: Source{Kind: SourceFile, Location: big},
+				Reason: "too big (>1MB)",
+			}))
+
+			c, report = scan(MaxFileSize(2 * 1024))
+			Expect(*c).To(BeEmpty())
+			Expect(report.Skipped).To(ContainElement(HaveField("Reason", "too big (>2KB)")))
+
+			o := &Options{}
+			Expect(o.Apply(MaxFileSize(0))).ToNot(Succeed())
+		})
+	})
+
+	Describe("files as directories", func() {
+		It("reads a file given instead of a directory", func() {
+			f := write(dirA, "config.yaml", "foo: bar\n")
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(f))).To(Succeed())
+			c, report, err := ScanWithReport(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(Equal(Config{"foo": "bar"}))
+			Expect(report.Files).To(Equal([]string{f}))
+		})
+
+		It("filters it and limits its size", func() {
+			f := write(dirA, "config.yaml", "foo: "+strings.Repeat("a", 2048)+"\n")
+			for _, opt := range []Option{ExcludeFiles("*.yaml"), IncludeFiles("*.yml"), MaxFileSize(1024)} {
+				o := &Options{}
+				Expect(o.Apply(NoLogs, Directories(f), opt)).To(Succeed())
+				c, report, err := ScanWithReport(o, FilterKeysTest)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(*c).To(BeEmpty())
+				Expect(report.Skipped).To(ContainElement(HaveField("Source", Source{Kind: SourceFile, Location: f})))
+			}
+		})
+	})
+
+	Describe("filters", func() {
+		BeforeEach(func() {
+			write(dirA, "90-user.yaml", "user: true\n")
+			write(dirA, "sub/90-user.yaml", "sub: true\n")
+			write(dirA, "sub/deeper/config.yaml", "deeper: true\n")
+			write(dirB, "00-base.yaml", "base: true\n")
+			write(dirB, "99_debug.yaml", "debug: true\n")
+		})
+
+		It("reads only the included files and none of the excluded ones", func() {
+			c, report := scan(IncludeFiles("90-*"), ExcludeFiles("sub/*"))
+			Expect(*c).To(Equal(Config{"user": true}))
+			Expect(report.Files).To(Equal([]string{filepath.Join(dirA, "90-user.yaml")}))
+			Expect(report.Skipped).To(ContainElement(SkippedSource{
+				Source: Source{Kind: SourceFile, Location: filepath.Join(dirB, "00-base.yaml")},
+				Reason: "excluded",
+			}))
+
+			c, _ = scan(ExcludeFiles("*debug*", "sub"))
+			Expect(*c).To(Equal(Config{"user": true, "sub": true, "deeper": true, "base": true}))
+
+			o := &Options{}
+			Expect(o.Apply(IncludeFiles("["))).ToNot(Succeed())
+		})
+
+		It("limits the depth of the subdirectories", func() {
+			c, _ := scan(MaxDirectoryDepth(1))
+			Expect(*c).To(Equal(Config{"user": true, "base": true, "debug": true}))
+
+			c, _ = scan(MaxDirectoryDepth(2))
+			Expect(*c).To(HaveKey("sub"))
+			Expect(*c).ToNot(HaveKey("deeper"))
+		})
+	})
+
+	Describe("ordering", func() {
+		BeforeEach(func() {
+			write(dirA, "10-network.yaml", "value: a10\n")
+			write(dirA, "9-base.yaml", "value: a9\n")
+			write(dirA, "zz.yaml", "value: azz\n")
+			write(dirB, "05-override.yaml", "value: b05\n")
+			write(dirB, "10-network.yaml", "value: b10\n")
+		})
+
+		It("merges directories in order by default", func() {
+			c, report := scan()
+			Expect((*c)["value"]).To(Equal("b10"))
+			Expect(report.Files).To(Equal([]string{
+				filepath.Join(dirA, "10-network.yaml"),
+				filepath.Join(dirA, "9-base.yaml"),
+				filepath.Join(dirA, "zz.yaml"),
+				filepath.Join(dirB, "05-override.yaml"),
+				filepath.Join(dirB, "10-network.yaml"),
+			}))
+		})
+
+		It("merges files by name across directories", func() {
+			c, report := scan(OrderFiles(FileOrderBasename))
+			Expect((*c)["value"]).To(Equal("azz"))
+			Expect(report.Files).To(Equal([]string{
+				filepath.Join(dirB, "05-override.yaml"),
+				filepath.Join(dirA, "10-network.yaml"),
+				filepath.Join(dirB, "10-network.yaml"),
+				filepath.Join(dirA, "9-base.yaml"),
+				filepath.Join(dirA, "zz.yaml"),
+			}))
+		})
+
+		It("merges files by numeric prefix", func() {
+			c, report := scan(OrderFiles(FileOrderNumericPrefix))
+			Expect((*c)["value"]).To(Equal("azz"))
+			Expect(report.Files).To(Equal([]string{
+				filepath.Join(dirB, "05-override.yaml"),
+				filepath.Join(dirA, "9-base.yaml"),
+				filepath.Join(dirA, "10-network.yaml"),
+				filepath.Join(dirB, "10-network.yaml"),
+				filepath.Join(dirA, "zz.yaml"),
+			}))
+			Expect(report.Accepted).To(HaveLen(5))
+			Expect(report.Accepted[1].Location).To(Equal(filepath.Join(dirA, "9-base.yaml")))
+		})
+
+		It("rejects unknown orders", func() {
+			o := &Options{}
+			Expect(o.Apply(OrderFiles("random"))).ToNot(Succeed())
+		})
+	})
+})
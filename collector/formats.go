@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a config file, given by its extension.
+type Format string
+
+const (
+	// FormatYAML files can have several documents, each one with its own
+	// header. Every document is merged in order.
+	FormatYAML Format = "yaml"
+	// FormatJSON files have one of the ValidFileHeaders as a top level key
+	// instead of a header, e.g. {"#cloud-config": true, "hostname": "foo"}.
+	// The key is removed before merging.
+	FormatJSON Format = "json"
+	// FormatTOML files have a header like YAML files, as it's a TOML comment.
+	FormatTOML Format = "toml"
+)
+
+// formatOf returns the Format of the file, or false if the extension is not
+// one of a config file.
+func formatOf(file string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return FormatYAML, true
+	case ".json":
+		return FormatJSON, true
+	case ".toml":
+		return FormatTOML, true
+	}
+	return "", false
+}
+
+// document is one of the documents of a config file.
+type document struct {
+	content []byte
+	// line is the 0-based line of the file the document starts at.
+	line int
+}
+
+// withLines returns the content of the document padded with empty lines, so
+// line numbers match the ones of the file it comes from.
+func (d document) withLines() []byte {
+	return append([]byte(strings.Repeat("\n", d.line)), d.content...)
+}
+
+var documentSeparator = regexp.MustCompile(`^---(\s|$)`)
+
+// splitDocuments splits the content of a file in its YAML documents. Other
+// formats have only one. Documents without content, like the comments before
+// the first separator, are joined to the next one, as it's where they belong.
+func splitDocuments(format Format, content []byte) []document {
+	if format != FormatYAML {
+		return []document{{content: content}}
+	}
+
+	docs := []document{}
+	current := document{}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	for i, line := range lines {
+		if documentSeparator.Match(line) && hasContent(current.content) {
+			docs = append(docs, current)
+			current = document{line: i}
+		}
+		current.content = append(current.content, line...)
+	}
+	if hasContent(current.content) || len(docs) == 0 {
+		docs = append(docs, current)
+	}
+	return docs
+}
+
+// hasContent returns true if the YAML has something other than comments,
+// blank lines and document markers.
+func hasContent(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !documentSeparator.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHeader returns true if the document looks like a config. JSON documents
+// are only fully checked when decoded, see decodeDocument.
+func hasHeader(format Format, content []byte) bool {
+	if format == FormatJSON {
+		for _, h := range ValidFileHeaders {
+			if bytes.Contains(content, []byte(fmt.Sprintf("%q", h))) {
+				return true
+			}
+		}
+		return false
+	}
+	return HasValidHeader(string(content))
+}
+
+// decodeDocument parses a document of the given format into a Config.
+func decodeDocument(format Format, content []byte) (*Config, error) {
+	c := &Config{}
+	switch format {
+	case FormatJSON:
+		// JSON is YAML, decoding it as such gets the same types for all formats.
+		if err := yaml.Unmarshal(content, c); err != nil {
+			return nil, err
+		}
+		found := false
+		for _, h := range ValidFileHeaders {
+			if _, ok := (*c)[h]; ok {
+				found = true
+				delete(*c, h)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no header key, like %q, found", DefaultHeader)
+		}
+	case FormatTOML:
+		m := map[string]interface{}{}
+		if err := toml.Unmarshal(content, &m); err != nil {
+			return nil, err
+		}
+		dat, err := yaml.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(dat, c); err != nil {
+			return nil, err
+		}
+	default:
+		if err := yaml.Unmarshal(content, c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,156 @@
+package collector_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config formats", func() {
+	var tmpDir string
+	var err error
+
+	write := func(name, content string) string {
+		f := filepath.Join(tmpDir, name)
+		Expect(os.WriteFile(f, []byte(content), os.ModePerm)).To(Succeed())
+		return f
+	}
+	scan := func(opts ...Option) (*Config, *ScanReport) {
+		o := &Options{}
+		Expect(o.Apply(append([]Option{NoLogs, Directories(tmpDir)}, opts...)...)).To(Succeed())
+		c, report, err := ScanWithReport(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		return c, report
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "formats")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("multi-document YAML", func() {
+		It("merges every document with a header in order", func() {
+			f := write("config.yaml", `#cloud-config
+hostname: first
+install:
+  device: /dev/sda
+---
+#cloud-config
+hostname: second
+---
+# not a config
+hostname: ignored
+---
+#kairos-config
+install:
+  auto: true
+`)
+			c, report := scan()
+			Expect(*c).To(Equal(Config{
+				"hostname": "second",
+				"install":  Config{"device": "/dev/sda", "auto": true},
+			}))
+			Expect(report.Accepted).To(Equal([]Source{
+				{Kind: SourceFile, Location: f, Document: 1},
+				{Kind: SourceFile, Location: f, Document: 2},
+				{Kind: SourceFile, Location: f, Document: 4},
+			}))
+			Expect(report.Skipped).To(ContainElement(SkippedSource{
+				Source: Source{Kind: SourceFile, Location: f, Document: 3},
+				Reason: "no valid header",
+			}))
+			Expect(report.Provenance["install.auto"].Source.String()).To(Equal("file:" + f + "#4"))
+		})
+
+		It("keeps the comments before the first separator with the first document", func() {
+			f := write("config.yaml", "#cloud-config\n---\nhostname: foo\n")
+			c, report := scan()
+			Expect(*c).To(Equal(Config{"hostname": "foo"}))
+			Expect(report.Accepted).To(Equal([]Source{{Kind: SourceFile, Location: f}}))
+		})
+
+		It("locates validation errors in the file", func() {
+			write("config.yaml", `#cloud-config
+hostname: foo
+---
+#cloud-config
+install:
+  device: 5
+`)
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir), StrictValidation(true))).To(Succeed())
+			_, _, err := ScanWithReport(o, FilterKeysTest)
+			Expect(err).To(HaveOccurred())
+			var verrs ValidationErrors
+			Expect(err).To(BeAssignableToTypeOf(verrs))
+			verrs = err.(ValidationErrors)
+			Expect(verrs[0].Source.Document).To(Equal(2))
+			Expect(verrs[0].Line).To(Equal(6))
+		})
+
+		It("merges the documents of readers", func() {
+			c, report := scan(Readers(strings.NewReader("hostname: a\n---\nhostname: b\nfoo: bar\n")))
+			Expect(*c).To(Equal(Config{"hostname": "b", "foo": "bar"}))
+			Expect(report.Accepted).To(HaveLen(2))
+		})
+	})
+
+	Describe("JSON", func() {
+		It("reads files with a header key", func() {
+			write("config.json", `{"#cloud-config": true, "hostname": "json", "install": {"device": "/dev/vda"}}`)
+			c, _ := scan()
+			Expect(*c).To(Equal(Config{
+				"hostname": "json",
+				"install":  Config{"device": "/dev/vda"},
+			}))
+		})
+
+		It("skips files without a header key", func() {
+			f := write("other.json", `{"hostname": "json"}`)
+			nested := write("nested.json", `{"hostname": "json", "labels": {"#cloud-config": "no"}}`)
+			c, report := scan()
+			Expect(*c).To(BeEmpty())
+			Expect(report.Skipped).To(ContainElement(SkippedSource{
+				Source: Source{Kind: SourceFile, Location: f},
+				Reason: "no valid header",
+			}))
+			Expect(report.Skipped).To(ContainElement(HaveField("Source.Location", nested)))
+		})
+	})
+
+	Describe("TOML", func() {
+		It("reads files with a header", func() {
+			write("config.toml", `#cloud-config
+hostname = "toml"
+
+[install]
+device = "/dev/vdb"
+auto = true
+
+[[users]]
+name = "kairos"
+`)
+			c, _ := scan()
+			Expect(*c).To(Equal(Config{
+				"hostname": "toml",
+				"install":  Config{"device": "/dev/vdb", "auto": true},
+				"users":    []interface{}{Config{"name": "kairos"}},
+			}))
+		})
+
+		It("rejects invalid TOML", func() {
+			write("config.toml", "#cloud-config\nhostname = \n")
+			c, report := scan()
+			Expect(*c).To(BeEmpty())
+			Expect(report.Skipped).To(ContainElement(HaveField("Reason", HavePrefix("invalid TOML:"))))
+		})
+	})
+})
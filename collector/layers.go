@@ -0,0 +1,275 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the well known layers of a Kairos config.
+const (
+	LayerDefaults   = "defaults"
+	LayerImage      = "image"
+	LayerOEM        = "oem"
+	LayerUserdata   = "userdata"
+	LayerCmdline    = "cmdline"
+	LayerEnv        = "env"
+	LayerOverwrites = "overwrites"
+)
+
+// DefaultLayerPriorities are the priorities of the well known layers. They are
+// apart so custom layers can be placed in between.
+var DefaultLayerPriorities = map[string]int{
+	LayerDefaults:   0,
+	LayerImage:      100,
+	LayerOEM:        200,
+	LayerUserdata:   300,
+	LayerCmdline:    400,
+	LayerEnv:        500,
+	LayerOverwrites: 600,
+}
+
+// Layer is a named Config merged with the others in order of priority.
+type Layer struct {
+	Name string `yaml:"name" json:"name"`
+	// Priority sets the order layers are merged in. Layers with higher
+	// priority are merged later, so their values win. Layers with the same
+	// priority are merged in the order they were added.
+	Priority int     `yaml:"priority" json:"priority"`
+	Config   *Config `yaml:"config" json:"config"`
+	// Replace sets the top level keys of the layer instead of merging them, as
+	// Options.Overwrites does.
+	Replace bool `yaml:"replace,omitempty" json:"replace,omitempty"`
+}
+
+// NewLayer returns a layer with the priority of the well known layer with the
+// same name in DefaultLayerPriorities, or 0 for other names.
+func NewLayer(name string, c *Config) Layer {
+	return Layer{Name: name, Priority: DefaultLayerPriorities[name], Config: c}
+}
+
+// Layers are the layers of a Config, see Effective.
+type Layers []Layer
+
+// Sorted returns the layers in the order they are merged.
+func (ls Layers) Sorted() Layers {
+	sorted := append(Layers{}, ls...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	return sorted
+}
+
+// Effective returns the result of merging all the layers in order of
+// priority.
+func (ls Layers) Effective() (*Config, error) {
+	result := &Config{}
+	for _, l := range ls.Sorted() {
+		if l.Config == nil {
+			continue
+		}
+		if l.Replace {
+			dat, err := yaml.Marshal(l.Config)
+			if err != nil {
+				return result, fmt.Errorf("layer %s: %w", l.Name, err)
+			}
+			if err := yaml.Unmarshal(dat, result); err != nil {
+				return result, fmt.Errorf("layer %s: %w", l.Name, err)
+			}
+			continue
+		}
+		if err := result.MergeConfig(l.Config); err != nil {
+			return result, fmt.Errorf("layer %s: %w", l.Name, err)
+		}
+	}
+	return result, nil
+}
+
+// Without returns the layers without the ones with the given names.
+func (ls Layers) Without(names ...string) Layers {
+	result := Layers{}
+	for _, l := range ls {
+		skip := false
+		for _, n := range names {
+			if l.Name == n {
+				skip = true
+			}
+		}
+		if !skip {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Get returns the layer with the given name.
+func (ls Layers) Get(name string) (Layer, bool) {
+	for _, l := range ls {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Layer{}, false
+}
+
+// LayerValue is the value a layer sets for a key.
+type LayerValue struct {
+	Layer    string      `yaml:"layer" json:"layer"`
+	Priority int         `yaml:"priority" json:"priority"`
+	Value    interface{} `yaml:"value" json:"value"`
+}
+
+// Explanation shows how the effective value of a key was set.
+type Explanation struct {
+	Key   string      `yaml:"key" json:"key"`
+	Value interface{} `yaml:"value" json:"value"`
+	// Layer is the last layer that set the key. Lists and maps can have
+	// values from the previous layers too, as they are merged.
+	Layer string `yaml:"layer,omitempty" json:"layer,omitempty"`
+	// Values are the values set by every layer for the key, in the order they
+	// are merged.
+	Values []LayerValue `yaml:"values,omitempty" json:"values,omitempty"`
+}
+
+// Explain returns the effective value of the key, like "install.device", and
+// the value every layer sets for it.
+func (ls Layers) Explain(key string) (*Explanation, error) {
+	effective, err := ls.Effective()
+	if err != nil {
+		return nil, err
+	}
+	value, err := queryKey(*effective, key)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Explanation{Key: key, Value: value}
+	for _, l := range ls.Sorted() {
+		if l.Config == nil {
+			continue
+		}
+		v, err := queryKey(*l.Config, key)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		e.Layer = l.Name
+		e.Values = append(e.Values, LayerValue{Layer: l.Name, Priority: l.Priority, Value: v})
+	}
+	return e, nil
+}
+
+// String shows the effective value of the key, followed by the layers that
+// set it, the one that wins last.
+func (e *Explanation) String() string {
+	sb := &strings.Builder{}
+	if e.Layer == "" {
+		fmt.Fprintf(sb, "%s is not set\n", e.Key)
+		return sb.String()
+	}
+	fmt.Fprintf(sb, "%s: %s (from %s)\n", e.Key, jsonValue(e.Value), e.Layer)
+	for _, v := range e.Values {
+		fmt.Fprintf(sb, "  %s (%d): %s\n", v.Layer, v.Priority, jsonValue(v.Value))
+	}
+	return sb.String()
+}
+
+// queryKey returns the value of the key in the Config or nil if it's not set.
+func queryKey(c Config, key string) (interface{}, error) {
+	values, err := c.QueryValues(key)
+	if err != nil {
+		return nil, err
+	}
+	return values.First().Interface(), nil
+}
+
+// ScanLayers reads the same sources as Scan but returns them as layers
+// instead of merging them: the files and readers as the userdata layer, and
+// the cmdline, environment and Overwrites as the layers of the same name. The
+// config_url of every layer is resolved and merged into it. Scan returns the
+// Effective config of these layers, but unlike it, ScanLayers doesn't resolve
+// secrets nor validate the result.
+func ScanLayers(o *Options, filter func(d []byte) ([]byte, error)) (Layers, error) {
+	return newScanState(o).scanLayers(o, filter)
+}
+
+// scanLayers returns the layers of ScanLayers and records the sources they
+// come from in the report of the state.
+func (s *scanState) scanLayers(o *Options, filter func(d []byte) ([]byte, error)) (Layers, error) {
+	// A nil filter keeps the cmdline and environment keys as they are.
+	if filter == nil {
+		filter = func(d []byte) ([]byte, error) { return d, nil }
+	}
+	fileConfigs, fileSources := parseFiles(o.ScanDir, s)
+	readerConfigs, readerSources := parseReaders(o.Readers, s)
+	userdata, err := append(fileConfigs, readerConfigs...).merge(append(fileSources, readerSources...), s)
+	if err != nil {
+		return nil, err
+	}
+	layers := Layers{NewLayer(LayerUserdata, userdata)}
+
+	if o.MergeBootCMDLine {
+		source := Source{Kind: SourceCmdline, Location: cmdlineFile(o.BootCMDLineFile)}
+		cConfig, err := parseCmdLine(o.BootCMDLineFile, filter, o)
+		if err == nil { // best-effort
+			s.accept(source)
+			s.validateSource(source, nil, cConfig)
+			if err := s.resolveConfigURL(source, cConfig); err != nil {
+				return nil, err
+			}
+			layers = append(layers, NewLayer(LayerCmdline, cConfig))
+		} else {
+			s.skip(source, zerolog.WarnLevel, fmt.Sprintf("parsing cmdline: %s", err))
+		}
+	}
+
+	if o.EnvPrefix != "" {
+		source := Source{Kind: SourceEnv, Location: o.EnvPrefix}
+		eConfig, err := ParseEnv(o.EnvPrefix, os.Environ(), filter)
+		if err == nil {
+			s.accept(source)
+			s.validateSource(source, nil, eConfig)
+			if err := s.resolveConfigURL(source, eConfig); err != nil {
+				return nil, err
+			}
+			layers = append(layers, NewLayer(LayerEnv, eConfig))
+		} else {
+			s.skip(source, zerolog.WarnLevel, fmt.Sprintf("parsing environment: %s", err))
+		}
+	}
+
+	if o.Overwrites != "" {
+		source := Source{Kind: SourceOverwrites}
+		overwrites := &Config{}
+		if err := yaml.Unmarshal([]byte(o.Overwrites), overwrites); err == nil {
+			s.report.Provenance.replace(newProvenance(source, overwrites))
+			s.accept(source)
+			s.validateSource(source, []byte(o.Overwrites), overwrites)
+			layer := NewLayer(LayerOverwrites, overwrites)
+			layer.Replace = true
+			layers = append(layers, layer)
+		} else {
+			s.skip(source, zerolog.WarnLevel, fmt.Sprintf("parsing overwrites: %s", err))
+		}
+	}
+
+	return layers, nil
+}
+
+// resolveConfigURL merges the config_url of the config of a layer into it, as
+// Configs.merge does, but keeping its merge directives so they apply when the
+// layer is merged with the previous ones.
+func (s *scanState) resolveConfigURL(source Source, c *Config) error {
+	p := newProvenance(source, c)
+	if err := c.mergeConfigURL(p, newConfigURLChain(s)); err != nil {
+		return err
+	}
+	s.report.Provenance.merge(p)
+	return nil
+}
@@ -0,0 +1,157 @@
+package collector_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Layers", func() {
+	parse := func(content string) *Config {
+		c := &Config{}
+		Expect(yaml.Unmarshal([]byte(content), c)).To(Succeed())
+		return c
+	}
+
+	var layers Layers
+
+	BeforeEach(func() {
+		// Added out of order on purpose, they are merged by priority.
+		layers = Layers{
+			NewLayer(LayerCmdline, parse("install:\n  device: /dev/vda\n")),
+			NewLayer(LayerDefaults, parse("install:\n  device: auto\n  auto: false\nhostname: kairos\n")),
+			NewLayer(LayerUserdata, parse("install:\n  auto: true\n  bind_mounts: [/var/lib/a]\n")),
+			NewLayer(LayerOEM, parse("install:\n  device: /dev/sda\n  bind_mounts: [/var/lib/oem]\n")),
+			{Name: "site", Priority: 350, Config: parse("hostname: site\n")},
+		}
+	})
+
+	It("merges the layers by priority", func() {
+		c, err := layers.Effective()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*c).To(Equal(Config{
+			"hostname": "site",
+			"install": Config{
+				"device":      "/dev/vda",
+				"auto":        true,
+				"bind_mounts": []interface{}{"/var/lib/oem", "/var/lib/a"},
+			},
+		}))
+		Expect(layers.Sorted()[0].Name).To(Equal(LayerDefaults))
+	})
+
+	It("merges without some layers", func() {
+		c, err := layers.Without(LayerCmdline, "site").Effective()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*c).To(HaveKeyWithValue("hostname", "kairos"))
+		Expect((*c)["install"]).To(HaveKeyWithValue("device", "/dev/sda"))
+		Expect(layers).To(HaveLen(5))
+	})
+
+	It("replaces the top level keys of replace layers", func() {
+		overwrites := NewLayer(LayerOverwrites, parse("install:\n  device: /dev/sdb\n"))
+		overwrites.Replace = true
+		c, err := append(layers, overwrites).Effective()
+		Expect(err).ToNot(HaveOccurred())
+		Expect((*c)["install"]).To(Equal(Config{"device": "/dev/sdb"}))
+	})
+
+	It("explains the value of a key", func() {
+		e, err := layers.Explain("install.device")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Value).To(Equal("/dev/vda"))
+		Expect(e.Layer).To(Equal(LayerCmdline))
+		Expect(e.Values).To(Equal([]LayerValue{
+			{Layer: LayerDefaults, Priority: 0, Value: "auto"},
+			{Layer: LayerOEM, Priority: 200, Value: "/dev/sda"},
+			{Layer: LayerCmdline, Priority: 400, Value: "/dev/vda"},
+		}))
+		Expect(e.String()).To(Equal(`install.device: "/dev/vda" (from cmdline)
+  defaults (0): "auto"
+  oem (200): "/dev/sda"
+  cmdline (400): "/dev/vda"
+`))
+
+		e, err = layers.Explain("install.missing")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Layer).To(BeEmpty())
+		Expect(e.String()).To(Equal("install.missing is not set\n"))
+	})
+
+	It("scans the sources as layers", func() {
+		tmpDir, err := os.MkdirTemp("", "layers")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+		Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\nconfig_url: file\noptions:\n  foo: file\n"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "cmdline"), []byte("options.foo=cmdline"), os.ModePerm)).To(Succeed())
+
+		o := &Options{}
+		Expect(o.Apply(NoLogs, Directories(tmpDir), MergeBootLine,
+			WithBootCMDLineFile(filepath.Join(tmpDir, "cmdline")),
+			Overwrites("config_url: overwritten\n"),
+		)).To(Succeed())
+		scanned, err := ScanLayers(o, FilterKeysTestMerge)
+		Expect(err).ToNot(HaveOccurred())
+
+		userdata, found := scanned.Get(LayerUserdata)
+		Expect(found).To(BeTrue())
+		Expect(*userdata.Config).To(HaveKeyWithValue("config_url", "file"))
+
+		e, err := scanned.Explain("options.foo")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Layer).To(Equal(LayerCmdline))
+
+		c, err := scanned.Effective()
+		Expect(err).ToNot(HaveOccurred())
+		scannedConfig, err := Scan(o, FilterKeysTestMerge)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal(scannedConfig))
+	})
+
+	It("resolves the config_url of every layer, as Scan does", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("#cloud-config\nremote: \"yes\"\n")) //nolint:errcheck
+		}))
+		defer server.Close()
+
+		tmpDir, err := os.MkdirTemp("", "layers")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+		Expect(os.WriteFile(filepath.Join(tmpDir, "cmdline"), []byte("config_url="+server.URL), os.ModePerm)).To(Succeed())
+
+		o := &Options{}
+		Expect(o.Apply(NoLogs, MergeBootLine, WithBootCMDLineFile(filepath.Join(tmpDir, "cmdline")))).To(Succeed())
+		scannedConfig, err := Scan(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*scannedConfig).To(HaveKeyWithValue("remote", "yes"))
+
+		scanned, err := ScanLayers(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		c, err := scanned.Effective()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal(scannedConfig))
+
+		e, err := scanned.Explain("remote")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Layer).To(Equal(LayerCmdline))
+	})
+
+	It("skips invalid overwrites, as Scan does", func() {
+		o := &Options{}
+		Expect(o.Apply(NoLogs, Overwrites("install: [\n"))).To(Succeed())
+		scanned, err := ScanLayers(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		_, found := scanned.Get(LayerOverwrites)
+		Expect(found).To(BeFalse())
+
+		_, report, err := ScanWithReport(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Skipped).To(ContainElement(HaveField("Source", Source{Kind: SourceOverwrites})))
+	})
+})
@@ -0,0 +1,299 @@
+package collector
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergeDirectivesKey is the sidecar key holding the MergeStrategy of its sibling
+// keys, e.g.:
+//
+//	install:
+//	  $merge:
+//	    bind_mounts: replace
+//	  bind_mounts: [/usr/local]
+//
+// The same can be written with YAML tags, which are turned into the sidecar key
+// when the config is unmarshalled:
+//
+//	install:
+//	  bind_mounts: !replace [/usr/local]
+//
+// The "*" key applies to all the sibling keys without an explicit strategy.
+const MergeDirectivesKey = "$merge"
+
+// MergeStrategy defines how a value is merged with the one already present in
+// the config.
+type MergeStrategy string
+
+const (
+	// MergeDeep is the default strategy: maps are merged deeply, lists of maps
+	// are concatenated and lists of other types are joined without duplicates.
+	MergeDeep MergeStrategy = "merge"
+	// MergeReplace replaces the current value.
+	MergeReplace MergeStrategy = "replace"
+	// MergeAppend concatenates the new list after the current one.
+	MergeAppend MergeStrategy = "append"
+	// MergePrepend concatenates the new list before the current one.
+	MergePrepend MergeStrategy = "prepend"
+)
+
+// MergeByKey returns the strategy that merges lists of maps matching their items
+// by the value of the given field, e.g. MergeByKey("name") merges the users with
+// the same name instead of duplicating them. Items without a match are appended.
+// As a tag it's written as "!merge:name".
+func MergeByKey(field string) MergeStrategy {
+	return MergeStrategy(fmt.Sprintf("%s:%s", MergeDeep, field))
+}
+
+// key returns the field used to match items if the strategy is MergeByKey.
+func (s MergeStrategy) key() (string, bool) {
+	field, found := strings.CutPrefix(string(s), string(MergeDeep)+":")
+	return field, found && field != ""
+}
+
+func (s MergeStrategy) validate() error {
+	switch s {
+	case MergeDeep, MergeReplace, MergeAppend, MergePrepend:
+		return nil
+	}
+	if _, ok := s.key(); ok {
+		return nil
+	}
+	return fmt.Errorf("unknown merge strategy %q", s)
+}
+
+// mergeDirectives returns the strategy for every key of the map as defined in
+// its MergeDirectivesKey.
+func mergeDirectives(m map[string]interface{}) (map[string]MergeStrategy, error) {
+	result := map[string]MergeStrategy{}
+
+	raw, ok := m[MergeDirectivesKey]
+	if !ok || raw == nil {
+		return result, nil
+	}
+
+	var directives map[string]interface{}
+	switch t := raw.(type) {
+	case Config:
+		directives = t
+	case map[string]interface{}:
+		directives = t
+	default:
+		return result, fmt.Errorf("%s must be a map, got %T", MergeDirectivesKey, raw)
+	}
+
+	for k, v := range directives {
+		s, ok := v.(string)
+		if !ok {
+			return result, fmt.Errorf("invalid merge strategy for %q: %v", k, v)
+		}
+		strategy := MergeStrategy(s)
+		if err := strategy.validate(); err != nil {
+			return result, fmt.Errorf("invalid merge strategy for %q: %w", k, err)
+		}
+		result[k] = strategy
+	}
+
+	return result, nil
+}
+
+func strategyFor(directives map[string]MergeStrategy, key string) MergeStrategy {
+	if s, ok := directives[key]; ok {
+		return s
+	}
+	if s, ok := directives["*"]; ok {
+		return s
+	}
+	return MergeDeep
+}
+
+// mergeWithStrategy merges b into a following the given strategy.
+func mergeWithStrategy(a, b interface{}, strategy MergeStrategy) (interface{}, error) {
+	if strategy == MergeReplace {
+		return stripDirectives(b), nil
+	}
+	if a == nil || b == nil {
+		return DeepMerge(a, b)
+	}
+
+	sliceA, isSliceA := a.([]interface{})
+	sliceB, isSliceB := b.([]interface{})
+
+	if field, ok := strategy.key(); ok {
+		if !isSliceA || !isSliceB {
+			return DeepMerge(a, b)
+		}
+		return mergeSlicesByKey(sliceA, sliceB, field)
+	}
+
+	switch strategy {
+	case MergeAppend, MergePrepend:
+		if !isSliceA || !isSliceB {
+			return nil, fmt.Errorf("cannot %s %T to %T, both must be lists", strategy, b, a)
+		}
+		sliceB = stripDirectives(sliceB).([]interface{})
+		if strategy == MergeAppend {
+			return append(append([]interface{}{}, sliceA...), sliceB...), nil
+		}
+		return append(append([]interface{}{}, sliceB...), sliceA...), nil
+	}
+
+	return DeepMerge(a, b)
+}
+
+// mergeSlicesByKey merges each item of sliceB with the item of sliceA that has the
+// same value for field, appending it if there is none.
+func mergeSlicesByKey(sliceA, sliceB []interface{}, field string) ([]interface{}, error) {
+	result := append([]interface{}{}, sliceA...)
+
+	for _, itemB := range sliceB {
+		matched := false
+		keyB, hasKeyB := itemKey(itemB, field)
+		for i, itemA := range result {
+			if keyA, hasKeyA := itemKey(itemA, field); hasKeyB && hasKeyA && keyA == keyB {
+				merged, err := DeepMerge(itemA, itemB)
+				if err != nil {
+					return result, err
+				}
+				result[i] = merged
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result = append(result, stripDirectives(itemB))
+		}
+	}
+
+	return result, nil
+}
+
+// itemKey returns the value of the field if item is a map.
+func itemKey(item interface{}, field string) (interface{}, bool) {
+	var m map[string]interface{}
+	switch t := item.(type) {
+	case Config:
+		m = t
+	case map[string]interface{}:
+		m = t
+	default:
+		return nil, false
+	}
+	v, ok := m[field]
+	if !ok || v == nil || !reflect.TypeOf(v).Comparable() {
+		return nil, false
+	}
+	return v, ok
+}
+
+// stripDirectives returns a copy of v without any MergeDirectivesKey, as
+// directives only apply when merging and must not end up in the result.
+func stripDirectives(v interface{}) interface{} {
+	switch t := v.(type) {
+	case Config:
+		return Config(stripMapDirectives(t))
+	case map[string]interface{}:
+		return stripMapDirectives(t)
+	case []interface{}:
+		result := make([]interface{}, len(t))
+		for i, item := range t {
+			result[i] = stripDirectives(item)
+		}
+		return result
+	}
+	return v
+}
+
+func stripMapDirectives(m map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k == MergeDirectivesKey {
+			continue
+		}
+		result[k] = stripDirectives(v)
+	}
+	return result
+}
+
+// tagsToDirectives moves the merge strategies defined as YAML tags in the node
+// tree (e.g. "!replace") to MergeDirectivesKey entries of their parent map.
+func tagsToDirectives(node *yaml.Node) {
+	for _, child := range node.Content {
+		tagsToDirectives(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	var directives *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == MergeDirectivesKey && node.Content[i+1].Kind == yaml.MappingNode {
+			directives = node.Content[i+1]
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		strategy := MergeStrategy(strings.TrimPrefix(value.Tag, "!"))
+		if !strings.HasPrefix(value.Tag, "!") || strings.HasPrefix(value.Tag, "!!") || strategy.validate() != nil {
+			continue
+		}
+		// Let the value be resolved as if it had no tag
+		value.Tag = ""
+
+		if directives == nil {
+			directives = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: MergeDirectivesKey},
+				directives,
+			)
+		}
+		directives.Content = append(directives.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.Value},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(strategy)},
+		)
+	}
+}
+
+// toConfigMaps turns all the maps found in v into Configs, which is what the
+// YAML decoder does when unmarshalling into a Config.
+func toConfigMaps(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		result := make(Config, len(t))
+		for k, item := range t {
+			result[k] = toConfigMaps(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range t {
+			t[i] = toConfigMaps(item)
+		}
+		return t
+	}
+	return v
+}
+
+// UnmarshalYAML decodes the YAML document into the Config, keeping the merge
+// strategies set with tags as MergeDirectivesKey entries.
+func (c *Config) UnmarshalYAML(node *yaml.Node) error {
+	tagsToDirectives(node)
+
+	var m map[string]interface{}
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+
+	if *c == nil {
+		*c = Config{}
+	}
+	for k, v := range m {
+		(*c)[k] = toConfigMaps(v)
+	}
+
+	return nil
+}
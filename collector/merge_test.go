@@ -0,0 +1,162 @@
+package collector_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Merge strategies", func() {
+	var original, newConfig *Config
+
+	BeforeEach(func() {
+		original = &Config{}
+		Expect(yaml.Unmarshal([]byte(`#cloud-config
+install:
+  bind_mounts:
+  - /var/lib/a
+  - /var/lib/b
+  grub_options:
+    extra_cmdline: console=tty0
+users:
+- name: kairos
+  passwd: kairos
+- name: admin
+  passwd: admin
+stages:
+  boot:
+  - name: set hostname
+    hostname: foo
+`), original)).To(Succeed())
+		newConfig = &Config{}
+	})
+
+	It("replaces lists and maps with the !replace tag", func() {
+		Expect(yaml.Unmarshal([]byte(`#cloud-config
+install:
+  bind_mounts: !replace
+  - /var/lib/c
+  grub_options: !replace
+    extra_active_cmdline: quiet
+`), newConfig)).To(Succeed())
+
+		Expect(original.MergeConfig(newConfig)).To(Succeed())
+		Expect(original.Query("install")).To(Equal(`bind_mounts:
+    - /var/lib/c
+grub_options:
+    extra_active_cmdline: quiet
+`))
+	})
+
+	It("appends and prepends lists", func() {
+		Expect(yaml.Unmarshal([]byte(`#cloud-config
+install:
+  bind_mounts: !prepend
+  - /var/lib/b
+`), newConfig)).To(Succeed())
+
+		Expect(original.MergeConfig(newConfig)).To(Succeed())
+		Expect(original.Query("install.bind_mounts")).To(Equal("- /var/lib/b\n- /var/lib/a\n- /var/lib/b\n"))
+	})
+
+	It("merges lists of maps by key with the $merge sidecar key", func() {
+		Expect(yaml.Unmarshal([]byte(`#cloud-config
+$merge:
+  users: merge:name
+users:
+- name: kairos
+  passwd: changed
+- name: other
+stages:
+  $merge:
+    "*": merge:name
+  boot:
+  - name: set hostname
+    hostname: bar
+`), newConfig)).To(Succeed())
+
+		Expect(original.MergeConfig(newConfig)).To(Succeed())
+		Expect(original.String()).To(Equal(`#cloud-config
+
+install:
+    bind_mounts:
+        - /var/lib/a
+        - /var/lib/b
+    grub_options:
+        extra_cmdline: console=tty0
+stages:
+    boot:
+        - hostname: bar
+          name: set hostname
+users:
+    - name: kairos
+      passwd: changed
+    - name: admin
+      passwd: admin
+    - name: other
+`))
+	})
+
+	It("fails with unknown strategies", func() {
+		Expect(yaml.Unmarshal([]byte(`#cloud-config
+$merge:
+  users: overwrite
+users: []
+`), newConfig)).To(Succeed())
+
+		Expect(original.MergeConfig(newConfig)).ToNot(Succeed())
+	})
+
+	It("does not leave directives in the result", func() {
+		Expect(yaml.Unmarshal([]byte(`#cloud-config
+options: !merge:name
+- name: foo
+`), newConfig)).To(Succeed())
+
+		result, err := Configs{newConfig}.Merge()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.String()).To(Equal("#cloud-config\n\noptions:\n    - name: foo\n"))
+	})
+
+	It("is honoured by Scan for files and the cmdline", func() {
+		tmpDir, err := os.MkdirTemp("", "merge")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+
+		Expect(os.WriteFile(filepath.Join(tmpDir, "01.yaml"), []byte(`#cloud-config
+users:
+- name: kairos
+  passwd: kairos
+options:
+  device: /dev/sda
+  list: [a, b]
+`), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "02.yaml"), []byte(`#cloud-config
+users: !merge:name
+- name: kairos
+  groups: [admin]
+`), os.ModePerm)).To(Succeed())
+		cmdline := filepath.Join(tmpDir, "cmdline")
+		Expect(os.WriteFile(cmdline, []byte(`options.$merge.list=replace options.list=c`), os.ModePerm)).To(Succeed())
+
+		o := &Options{}
+		Expect(o.Apply(NoLogs, Directories(tmpDir), MergeBootLine, WithBootCMDLineFile(cmdline))).To(Succeed())
+		c, err := Scan(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.String()).To(Equal(`#cloud-config
+
+options:
+    device: /dev/sda
+    list: c
+users:
+    - groups:
+        - admin
+      name: kairos
+      passwd: kairos
+`))
+	})
+})
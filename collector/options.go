@@ -1,8 +1,16 @@
 package collector
 
 import (
+	"crypto"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kairos-io/kairos-sdk/types"
+	"github.com/rs/zerolog"
 )
 
 type Options struct {
@@ -13,6 +21,58 @@ type Options struct {
 	StrictValidation bool
 	Readers          []io.Reader
 	Overwrites       string
+	// EnvPrefix enables reading configuration from the environment variables
+	// starting with it. See ParseEnv.
+	EnvPrefix string
+	// RenderTemplates renders files, readers and remote configs as Go
+	// templates before parsing them. See TemplateData. Templates meant to be
+	// rendered later, e.g. by yip in stages, must be escaped.
+	RenderTemplates bool
+	// TemplateData is the data given to the templates. Defaults to the facts
+	// of the running system, see NewTemplateData.
+	TemplateData *TemplateData
+	// DecryptionKeyFiles hold the age identities or OpenPGP private keys used
+	// to decrypt the encrypted sections of the configs. Defaults to
+	// DefaultDecryptionKeyFiles.
+	DecryptionKeyFiles []string
+	// SignaturePolicy sets which configs must have a valid detached signature
+	// made with one of the SignatureKeys.
+	SignaturePolicy SignaturePolicy
+	SignatureKeys   []crypto.PublicKey
+	// MaxConfigURLDepth is the maximum number of remote configs followed in a
+	// config_url chain. Defaults to DefaultMaxConfigURLDepth.
+	MaxConfigURLDepth int
+	// MaxFileSize is the size, in bytes, of the biggest file read from the
+	// directories. Defaults to DefaultMaxFileSize.
+	MaxFileSize int64
+	// MaxDirectoryDepth is the number of directory levels scanned, 1 being
+	// only the files directly in ScanDir. 0 scans all the subdirectories.
+	MaxDirectoryDepth int
+	// IncludeFiles and ExcludeFiles are globs matched against the name of the
+	// files, or their path relative to the directory they are found in. If
+	// IncludeFiles is set only matching files are read. Files matching
+	// ExcludeFiles are never read.
+	IncludeFiles []string
+	ExcludeFiles []string
+	// FileOrder is the order files are merged in, see FileOrder.
+	FileOrder FileOrder
+	// CmdlineNamespaces and CmdlineAllowlist restrict the keys merged from the
+	// cmdline to the ones starting with a namespace, like "kairos.", which is
+	// removed from the key, and the allowed ones. All the keys are merged if
+	// none are set.
+	CmdlineNamespaces []string
+	CmdlineAllowlist  []string
+	// CmdlineExcludes are the keys never merged from the cmdline. Defaults to
+	// DefaultCmdlineExcludes.
+	CmdlineExcludes []string
+	// Logger receives the events of the scan. Defaults to a console logger.
+	Logger *types.KairosLogger
+	// Fetchers download the remote configs and keys of this scan by scheme,
+	// taking precedence over the ones set with RegisterFetcher.
+	Fetchers map[string]Fetcher
+	// Filter is the filter Watch scans with, Scan takes it as an argument.
+	// Defaults to keeping the configs as they are.
+	Filter func(d []byte) ([]byte, error)
 }
 
 type Option func(o *Options) error
@@ -22,13 +82,26 @@ var NoLogs Option = func(o *Options) error {
 	return nil
 }
 
-// SoftErr prints a warning if err is no nil and NoLogs is not true.
+// SoftErr logs a warning if err is no nil and NoLogs is not true.
 // It's use to wrap the same handling happening in multiple places.
-//
-// TODO: Switch to a standard logging library (e.g. verbose, silent mode etc).
 func (o *Options) SoftErr(message string, err error) {
-	if !o.NoLogs && err != nil {
-		fmt.Printf("WARNING: %s, %s\n", message, err.Error())
+	if err != nil {
+		l := o.logger()
+		l.Logger.Warn().Err(err).Msg(message)
+	}
+}
+
+// logger returns the Logger of the Options, a console logger if none is set
+// or a logger that discards everything if NoLogs is true.
+func (o *Options) logger() types.KairosLogger {
+	if o.NoLogs {
+		return types.NewNullLogger()
+	}
+	if o.Logger != nil {
+		return *o.Logger
+	}
+	return types.KairosLogger{
+		Logger: zerolog.New(zerolog.NewConsoleWriter()).With().Timestamp().Logger().Level(zerolog.InfoLevel),
 	}
 }
 
@@ -46,6 +119,65 @@ var MergeBootLine = func(o *Options) error {
 	return nil
 }
 
+var RenderTemplates Option = func(o *Options) error {
+	o.RenderTemplates = true
+	return nil
+}
+
+// WithFetcher sets the Fetcher used by the scan for the URLs with the given
+// scheme, without changing the ones registered for the whole process.
+func WithFetcher(scheme string, f Fetcher) Option {
+	return func(o *Options) error {
+		if o.Fetchers == nil {
+			o.Fetchers = map[string]Fetcher{}
+		}
+		o.Fetchers[strings.ToLower(scheme)] = f
+		return nil
+	}
+}
+
+// WithTemplateData renders the configs as templates with the given data
+// instead of the facts of the running system.
+func WithTemplateData(d TemplateData) Option {
+	return func(o *Options) error {
+		o.RenderTemplates = true
+		o.TemplateData = &d
+		return nil
+	}
+}
+
+// DecryptionKeys sets the files holding the keys to decrypt the encrypted
+// sections of the configs.
+func DecryptionKeys(files ...string) Option {
+	return func(o *Options) error {
+		o.DecryptionKeyFiles = files
+		return nil
+	}
+}
+
+// VerifySignatures sets the signature policy and reads the keys to verify the
+// signatures from the given files, see ParseVerificationKey.
+func VerifySignatures(policy SignaturePolicy, keyFiles ...string) Option {
+	return func(o *Options) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		o.SignaturePolicy = policy
+		for _, f := range keyFiles {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			key, err := ParseVerificationKey(data)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", f, err)
+			}
+			o.SignatureKeys = append(o.SignatureKeys, key)
+		}
+		return nil
+	}
+}
+
 func WithBootCMDLineFile(s string) Option {
 	return func(o *Options) error {
 		o.BootCMDLineFile = s
@@ -60,6 +192,14 @@ func StrictValidation(v bool) Option {
 	}
 }
 
+// WithFilter sets the filter Watch scans with.
+func WithFilter(f func(d []byte) ([]byte, error)) Option {
+	return func(o *Options) error {
+		o.Filter = f
+		return nil
+	}
+}
+
 func Directories(d ...string) Option {
 	return func(o *Options) error {
 		o.ScanDir = d
@@ -80,3 +220,140 @@ func Overwrites(m string) Option {
 		return nil
 	}
 }
+
+// Environment reads configuration from the environment variables starting with
+// prefix, e.g. KAIROS_INSTALL__DEVICE=/dev/sda with prefix "KAIROS_".
+func Environment(prefix string) Option {
+	return func(o *Options) error {
+		if prefix == "" {
+			return errors.New("environment prefix can't be empty")
+		}
+		o.EnvPrefix = prefix
+		return nil
+	}
+}
+
+// MaxConfigURLDepth sets the maximum number of remote configs followed in a
+// config_url chain, 0 meaning DefaultMaxConfigURLDepth.
+func MaxConfigURLDepth(d int) Option {
+	return func(o *Options) error {
+		if d < 0 {
+			return fmt.Errorf("invalid max config_url depth %d", d)
+		}
+		o.MaxConfigURLDepth = d
+		return nil
+	}
+}
+
+// MaxFileSize sets the size, in bytes, of the biggest file read.
+func MaxFileSize(bytes int64) Option {
+	return func(o *Options) error {
+		if bytes <= 0 {
+			return fmt.Errorf("invalid max file size %d", bytes)
+		}
+		o.MaxFileSize = bytes
+		return nil
+	}
+}
+
+// MaxDirectoryDepth sets the number of directory levels scanned, 1 being only
+// the files directly in the directories.
+func MaxDirectoryDepth(d int) Option {
+	return func(o *Options) error {
+		if d < 0 {
+			return fmt.Errorf("invalid max directory depth %d", d)
+		}
+		o.MaxDirectoryDepth = d
+		return nil
+	}
+}
+
+// IncludeFiles only reads the files matching one of the globs.
+func IncludeFiles(globs ...string) Option {
+	return func(o *Options) error {
+		if err := validateGlobs(globs); err != nil {
+			return err
+		}
+		o.IncludeFiles = append(o.IncludeFiles, globs...)
+		return nil
+	}
+}
+
+// ExcludeFiles doesn't read the files matching any of the globs.
+func ExcludeFiles(globs ...string) Option {
+	return func(o *Options) error {
+		if err := validateGlobs(globs); err != nil {
+			return err
+		}
+		o.ExcludeFiles = append(o.ExcludeFiles, globs...)
+		return nil
+	}
+}
+
+func validateGlobs(globs []string) error {
+	for _, g := range globs {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+	}
+	return nil
+}
+
+// OrderFiles sets the order files are merged in.
+func OrderFiles(order FileOrder) Option {
+	return func(o *Options) error {
+		if err := order.validate(); err != nil {
+			return err
+		}
+		o.FileOrder = order
+		return nil
+	}
+}
+
+// CmdlineNamespaces only merges the cmdline keys under one of the namespaces,
+// e.g. with "kairos" install.device is read from kairos.install.device.
+func CmdlineNamespaces(namespaces ...string) Option {
+	return func(o *Options) error {
+		for _, ns := range namespaces {
+			ns = strings.TrimSuffix(ns, ".")
+			if ns == "" {
+				return errors.New("cmdline namespace can't be empty")
+			}
+			o.CmdlineNamespaces = append(o.CmdlineNamespaces, ns+".")
+		}
+		return nil
+	}
+}
+
+// CmdlineAllowlist merges the cmdline keys, and the keys under them, matching
+// one of the globs even if they are not under one of the CmdlineNamespaces.
+func CmdlineAllowlist(keys ...string) Option {
+	return func(o *Options) error {
+		if err := validateGlobs(keys); err != nil {
+			return err
+		}
+		o.CmdlineAllowlist = append(o.CmdlineAllowlist, keys...)
+		return nil
+	}
+}
+
+// CmdlineExcludes replaces DefaultCmdlineExcludes with the given globs. With
+// no globs, no key is excluded.
+func CmdlineExcludes(keys ...string) Option {
+	return func(o *Options) error {
+		if err := validateGlobs(keys); err != nil {
+			return err
+		}
+		o.CmdlineExcludes = append([]string{}, keys...)
+		return nil
+	}
+}
+
+// WithLogger sends the events of the scan to the logger instead of the
+// console.
+func WithLogger(l types.KairosLogger) Option {
+	return func(o *Options) error {
+		o.Logger = &l
+		return nil
+	}
+}
@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SourceKind identifies the type of source a configuration was read from.
+type SourceKind string
+
+const (
+	SourceFile       SourceKind = "file"
+	SourceReader     SourceKind = "reader"
+	SourceCmdline    SourceKind = "cmdline"
+	SourceEnv        SourceKind = "env"
+	SourceConfigURL  SourceKind = "config_url"
+	SourceOverwrites SourceKind = "overwrites"
+	// SourceMerged is used when a value can't be attributed to a single source.
+	SourceMerged SourceKind = "merged"
+)
+
+// Source describes where a piece of configuration came from.
+type Source struct {
+	Kind SourceKind `yaml:"kind" json:"kind"`
+	// Location is the file path, URL or index of the source, depending on its Kind.
+	Location string `yaml:"location,omitempty" json:"location,omitempty"`
+	// Document is the 1-based index of the YAML document in sources with
+	// several of them, or 0 if the source has only one.
+	Document int `yaml:"document,omitempty" json:"document,omitempty"`
+}
+
+func (s Source) String() string {
+	if s.Location == "" {
+		return string(s.Kind)
+	}
+	if s.Document > 0 {
+		return fmt.Sprintf("%s:%s#%d", s.Kind, s.Location, s.Document)
+	}
+	return fmt.Sprintf("%s:%s", s.Kind, s.Location)
+}
+
+// KeyProvenance holds the source that set the final value of a key and the
+// sources that had set it before, in the order they were merged.
+type KeyProvenance struct {
+	Source    Source   `yaml:"source" json:"source"`
+	Overrides []Source `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+}
+
+// Provenance maps the dot separated path of every leaf key of a merged Config
+// (e.g. "install.device") to the sources that defined it.
+// Lists are considered leaves, so all the sources contributing to a list are
+// recorded under the path of the list itself.
+type Provenance map[string]*KeyProvenance
+
+// newProvenance returns the Provenance of a single config, where every key
+// comes from the given source.
+func newProvenance(source Source, c *Config) Provenance {
+	p := Provenance{}
+	if c == nil {
+		return p
+	}
+	for _, path := range leafPaths("", map[string]interface{}(*c)) {
+		p[path] = &KeyProvenance{Source: source}
+	}
+	return p
+}
+
+func leafPaths(prefix string, m map[string]interface{}) []string {
+	paths := []string{}
+	for k, v := range m {
+		if k == MergeDirectivesKey {
+			continue
+		}
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		var nested map[string]interface{}
+		switch t := v.(type) {
+		case Config:
+			nested = t
+		case map[string]interface{}:
+			nested = t
+		}
+
+		if len(nested) == 0 {
+			paths = append(paths, path)
+			continue
+		}
+		paths = append(paths, leafPaths(path, nested)...)
+	}
+	return paths
+}
+
+// isRelated returns true if one of the paths is equal to, or an ancestor of, the other one.
+func isRelated(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// drop removes every entry related to the given path and returns the sources
+// that had set them, once each.
+func (p Provenance) drop(path string) []Source {
+	var dropped []Source
+	seen := map[Source]bool{}
+	for _, k := range p.Keys() {
+		if !isRelated(k, path) {
+			continue
+		}
+		for _, s := range append(append([]Source{}, p[k].Overrides...), p[k].Source) {
+			if !seen[s] {
+				seen[s] = true
+				dropped = append(dropped, s)
+			}
+		}
+		delete(p, k)
+	}
+	return dropped
+}
+
+// merge records that other was deep merged on top of the config p belongs to.
+func (p Provenance) merge(other Provenance) {
+	for _, path := range other.Keys() {
+		kp := other[path]
+		overrides := append(p.drop(path), kp.Overrides...)
+		p[path] = &KeyProvenance{Source: kp.Source, Overrides: overrides}
+	}
+}
+
+// replace records that the top level keys of other replaced completely the
+// ones of the config p belongs to, as it happens with Overwrites.
+func (p Provenance) replace(other Provenance) {
+	dropped := map[string][]Source{}
+	for _, path := range other.Keys() {
+		top := strings.SplitN(path, ".", 2)[0]
+		if _, done := dropped[top]; !done {
+			dropped[top] = p.drop(top)
+		}
+		kp := other[path]
+		p[path] = &KeyProvenance{
+			Source:    kp.Source,
+			Overrides: append(append([]Source(nil), dropped[top]...), kp.Overrides...),
+		}
+	}
+}
+
+// Keys returns the sorted list of paths with a known provenance.
+func (p Provenance) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Query returns the provenance of the given dot separated key, using the same
+// notation as Config.Query (e.g. "install.device"). If the key is inside a
+// leaf (e.g. a list), the provenance of the leaf is returned.
+func (p Provenance) Query(key string) (*KeyProvenance, error) {
+	key = strings.TrimPrefix(key, ".")
+	for path := key; path != ""; {
+		if kp, ok := p[path]; ok {
+			return kp, nil
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return nil, fmt.Errorf("no provenance found for key %q", key)
+}
@@ -0,0 +1,117 @@
+package collector_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provenance", func() {
+	var tmpDir, serverDir, cmdLinePath string
+	var closeFunc ServerCloseFunc
+	var port int
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		serverDir, err = os.MkdirTemp("", "config_url")
+		Expect(err).ToNot(HaveOccurred())
+		closeFunc, port, err = startAssetServer(serverDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = os.WriteFile(filepath.Join(tmpDir, "01_base.yaml"), []byte(fmt.Sprintf(`#cloud-config
+config_url: http://127.0.0.1:%d/remote.yaml
+install:
+  device: /dev/sda
+  auto: false
+options:
+  foo: from-file
+`, port)), os.ModePerm)
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(filepath.Join(tmpDir, "02_override.yaml"), []byte(`#cloud-config
+install:
+  device: /dev/vda
+`), os.ModePerm)
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(filepath.Join(serverDir, "remote.yaml"), []byte(`#cloud-config
+install:
+  auto: true
+`), os.ModePerm)
+		Expect(err).ToNot(HaveOccurred())
+
+		cmdLinePath = filepath.Join(tmpDir, "cmdline")
+		err = os.WriteFile(cmdLinePath, []byte(`options.foo=from-cmdline`), os.ModePerm)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		closeFunc()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		Expect(os.RemoveAll(serverDir)).To(Succeed())
+	})
+
+	It("records the source of every key and the sources it overrode", func() {
+		o := &Options{}
+		err := o.Apply(
+			NoLogs,
+			MergeBootLine,
+			WithBootCMDLineFile(cmdLinePath),
+			Directories(tmpDir),
+			Overwrites("#cloud-config\nstrict: true\n"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		c, p, err := ScanWithProvenance(o, FilterKeysTestMerge)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Query("install.device")).To(Equal("/dev/vda\n"))
+
+		base := Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "01_base.yaml")}
+		override := Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "02_override.yaml")}
+
+		kp, err := p.Query("install.device")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kp.Source).To(Equal(override))
+		Expect(kp.Overrides).To(Equal([]Source{base}))
+
+		kp, err = p.Query("install.auto")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kp.Source).To(Equal(Source{Kind: SourceConfigURL, Location: fmt.Sprintf("http://127.0.0.1:%d/remote.yaml", port)}))
+		Expect(kp.Overrides).To(Equal([]Source{base}))
+
+		kp, err = p.Query("options.foo")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kp.Source).To(Equal(Source{Kind: SourceCmdline, Location: cmdLinePath}))
+		Expect(kp.Overrides).To(Equal([]Source{base}))
+
+		kp, err = p.Query("strict")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kp.Source.Kind).To(Equal(SourceOverwrites))
+
+		_, err = p.Query("install.nonexisting")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("records every source replaced by Overwrites once", func() {
+		o := &Options{}
+		err := o.Apply(NoLogs, Directories(tmpDir), Overwrites("#cloud-config\ninstall:\n  device: /dev/nvme0n1\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		c, p, err := ScanWithProvenance(o, FilterKeysTestMerge)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Query("install")).To(Equal("device: /dev/nvme0n1\n"))
+
+		kp, err := p.Query("install.device")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kp.Source.Kind).To(Equal(SourceOverwrites))
+		Expect(kp.Overrides).To(ConsistOf(
+			Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "01_base.yaml")},
+			Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "02_override.yaml")},
+			Source{Kind: SourceConfigURL, Location: fmt.Sprintf("http://127.0.0.1:%d/remote.yaml", port)},
+		))
+	})
+})
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/kairos-io/kairos-sdk/schema"
+	"github.com/kairos-io/kairos-sdk/types"
+	"github.com/rs/zerolog"
+)
+
+// SkippedSource is a source that was not merged and the reason why.
+type SkippedSource struct {
+	Source Source `yaml:"source" json:"source"`
+	Reason string `yaml:"reason" json:"reason"`
+}
+
+// ScanReport lists the sources found by Scan, in the order they were merged,
+// and the Provenance of every key of the result.
+type ScanReport struct {
+	// Files are the files found in the directories, once filtered, in the
+	// order they are merged. See FileOrder.
+	Files      []string        `yaml:"files,omitempty" json:"files,omitempty"`
+	Accepted   []Source        `yaml:"accepted,omitempty" json:"accepted,omitempty"`
+	Skipped    []SkippedSource `yaml:"skipped,omitempty" json:"skipped,omitempty"`
+	Provenance Provenance      `yaml:"provenance,omitempty" json:"provenance,omitempty"`
+}
+
+// scanState holds the settings and results shared by all the steps of a Scan.
+type scanState struct {
+	logger            types.KairosLogger
+	maxConfigURLDepth int
+	maxFileSize       int64
+	maxDirectoryDepth int
+	includeFiles      []string
+	excludeFiles      []string
+	fileOrder         FileOrder
+	report            *ScanReport
+	strict            bool
+	templates         bool
+	templateData      *TemplateData
+	// decryptionKeys are loaded from decryptionKeyFiles on first use.
+	decryptionKeyFiles []string
+	decryptionKeys     *decryptionKeys
+	signaturePolicy    SignaturePolicy
+	signatureKeys      []crypto.PublicKey
+	fetchers           map[string]Fetcher
+	// contents holds the raw content of every accepted source to locate validation errors.
+	contents map[Source][]byte
+	invalid  ValidationErrors
+}
+
+func newScanState(o *Options) *scanState {
+	maxFileSize := o.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+	return &scanState{
+		logger:             o.logger(),
+		maxConfigURLDepth:  o.MaxConfigURLDepth,
+		maxFileSize:        maxFileSize,
+		maxDirectoryDepth:  o.MaxDirectoryDepth,
+		includeFiles:       o.IncludeFiles,
+		excludeFiles:       o.ExcludeFiles,
+		fileOrder:          o.FileOrder,
+		report:             &ScanReport{Provenance: Provenance{}},
+		strict:             o.StrictValidation,
+		templates:          o.RenderTemplates,
+		templateData:       o.TemplateData,
+		decryptionKeyFiles: o.DecryptionKeyFiles,
+		signaturePolicy:    o.SignaturePolicy,
+		signatureKeys:      o.SignatureKeys,
+		fetchers:           o.Fetchers,
+		contents:           map[Source][]byte{},
+	}
+}
+
+// fetch downloads the url with the Fetcher of the scan for its scheme.
+func (s *scanState) fetch(u string) ([]byte, error) {
+	return fetch(u, s.fetchers)
+}
+
+func (s *scanState) accept(source Source) {
+	s.logger.Logger.Debug().
+		Str("kind", string(source.Kind)).
+		Str("source", source.Location).
+		Msg("accepted config")
+	s.report.Accepted = append(s.report.Accepted, source)
+}
+
+func (s *scanState) skip(source Source, level zerolog.Level, reason string) {
+	s.logger.Logger.WithLevel(level).
+		Str("kind", string(source.Kind)).
+		Str("source", source.Location).
+		Str("reason", reason).
+		Msg("skipping config")
+	s.report.Skipped = append(s.report.Skipped, SkippedSource{Source: source, Reason: reason})
+}
+
+// reject skips a source that can't be parsed or rendered, which is an error
+// with StrictValidation.
+func (s *scanState) reject(source Source, reason string, err error) {
+	s.skip(source, zerolog.WarnLevel, fmt.Sprintf("%s: %s", reason, err))
+	if s.strict {
+		s.addInvalid(ValidationError{Source: source, ValidationResult: schema.ValidationResult{Message: err.Error()}})
+	}
+}
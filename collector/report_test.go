@@ -0,0 +1,85 @@
+package collector_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	"github.com/kairos-io/kairos-sdk/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ScanReport", func() {
+	var tmpDir string
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "report")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(tmpDir, "valid.yaml"), []byte("#cloud-config\nfoo: bar\n"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "no_header.yaml"), []byte("foo: baz\n"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("#cloud-config\nfoo: baz\n"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "big.yaml"), []byte("#cloud-config\nfoo: "+strings.Repeat("a", 3*1024*1024)), os.ModePerm)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("lists accepted and skipped sources and logs them", func() {
+		buf := &bytes.Buffer{}
+		logger := types.NewBufferLogger(buf)
+		logger.SetLevel("debug")
+
+		o := &Options{}
+		Expect(o.Apply(
+			WithLogger(logger),
+			Directories(tmpDir),
+			MergeBootLine,
+			WithBootCMDLineFile(filepath.Join(tmpDir, "nonexisting")),
+		)).To(Succeed())
+
+		c, report, err := ScanWithReport(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect((*c)["foo"]).To(Equal("bar"))
+
+		Expect(report.Accepted).To(Equal([]Source{{Kind: SourceFile, Location: filepath.Join(tmpDir, "valid.yaml")}}))
+		Expect(report.Skipped).To(ConsistOf(
+			SkippedSource{Source: Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "big.yaml")}, Reason: "too big (>1MB)"},
+			SkippedSource{Source: Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "no_header.yaml")}, Reason: "no valid header"},
+			SkippedSource{Source: Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "notes.txt")}, Reason: "extension"},
+			HaveField("Source.Kind", SourceCmdline),
+		))
+		Expect(report.Provenance).To(HaveKey("foo"))
+
+		Expect(buf.String()).To(ContainSubstring(`"level":"warn","kind":"file","source":"` + filepath.Join(tmpDir, "no_header.yaml") + `","reason":"no valid header"`))
+		Expect(buf.String()).To(ContainSubstring(`"level":"debug","kind":"file","source":"` + filepath.Join(tmpDir, "notes.txt") + `","reason":"extension"`))
+	})
+
+	It("warns about the deprecated #node-config header", func() {
+		Expect(os.WriteFile(filepath.Join(tmpDir, "legacy.yaml"), []byte("#node-config\nlegacy: true\n"), os.ModePerm)).To(Succeed())
+		buf := &bytes.Buffer{}
+		o := &Options{}
+		Expect(o.Apply(WithLogger(types.NewBufferLogger(buf)), Directories(tmpDir))).To(Succeed())
+
+		c, err := Scan(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect((*c)["legacy"]).To(BeTrue())
+		Expect(buf.String()).To(MatchRegexp(`"level":"warn","kind":"file","source":"` + filepath.Join(tmpDir, "legacy.yaml") + `",.*"message":"the #node-config header is deprecated, use #cloud-config"`))
+	})
+
+	It("does not log anything with NoLogs", func() {
+		buf := &bytes.Buffer{}
+		o := &Options{}
+		Expect(o.Apply(WithLogger(types.NewBufferLogger(buf)), NoLogs, Directories(tmpDir))).To(Succeed())
+
+		_, report, err := ScanWithReport(o, FilterKeysTest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Skipped).To(HaveLen(3))
+		Expect(buf.String()).To(BeEmpty())
+	})
+})
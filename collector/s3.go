@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the hex encoded SHA-256 of an empty body, which is the payload
+// hash of GET requests.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Fetcher downloads configs from S3 compatible object stores, referenced as
+// s3://BUCKET/KEY. Requests are signed with AWS Signature Version 4 when
+// credentials are set, and are anonymous otherwise. The fields left empty are
+// read from the usual AWS environment variables.
+type S3Fetcher struct {
+	// Endpoint is the URL of the object store, e.g. "https://minio.local:9000".
+	// Defaults to $AWS_ENDPOINT_URL_S3, $AWS_ENDPOINT_URL or the AWS endpoint of
+	// the region.
+	Endpoint string
+	// VirtualHostStyle puts the bucket in the host of the requests instead of in
+	// their path. It's always used with the default AWS endpoint.
+	VirtualHostStyle bool
+	// Region the requests are signed for. Defaults to $AWS_REGION,
+	// $AWS_DEFAULT_REGION or "us-east-1".
+	Region string
+	// AccessKeyID defaults to $AWS_ACCESS_KEY_ID.
+	AccessKeyID string
+	// SecretAccessKey defaults to $AWS_SECRET_ACCESS_KEY.
+	SecretAccessKey string
+	// SessionToken is needed by temporary credentials. Defaults to
+	// $AWS_SESSION_TOKEN when the credentials are read from the environment.
+	SessionToken string
+	// HTTP runs the requests, with its headers, CA bundle and retries.
+	HTTP HTTPFetcher
+	// Now returns the time the requests are signed at. Defaults to time.Now.
+	Now func() time.Time
+}
+
+func (f *S3Fetcher) Fetch(u string) ([]byte, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := parsed.Host, strings.TrimPrefix(parsed.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid s3 url %q, expected s3://BUCKET/KEY", u)
+	}
+
+	region := firstNonEmpty(f.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	endpoint := firstNonEmpty(f.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+	virtualHost := f.VirtualHostStyle
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		virtualHost = true
+	}
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the s3 endpoint: %w", err)
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/")
+	if virtualHost {
+		target.Host = bucket + "." + target.Host
+		target.Path += "/" + key
+	} else {
+		target.Path += "/" + bucket + "/" + key
+	}
+	target.RawPath = s3Escape(target.Path)
+	target.RawQuery = ""
+
+	accessKey, secretKey, token := f.AccessKeyID, f.SecretAccessKey, f.SessionToken
+	if accessKey == "" && secretKey == "" {
+		accessKey, secretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		token = firstNonEmpty(token, os.Getenv("AWS_SESSION_TOKEN"))
+	}
+	if accessKey == "" {
+		return f.HTTP.fetch(target.String(), nil)
+	}
+
+	now := time.Now
+	if f.Now != nil {
+		now = f.Now
+	}
+	return f.HTTP.fetch(target.String(), func(req *http.Request) error {
+		signV4(req, now().UTC(), region, accessKey, secretKey, token)
+		return nil
+	})
+}
+
+// signV4 sets the Authorization header of the GET request, which has no query,
+// following the AWS Signature Version 4 for S3, signing all its headers.
+func signV4(req *http.Request, t time.Time, region, accessKey, secretKey, token string) {
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	canonicalHeaders := &strings.Builder{}
+	for _, k := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape percent encodes everything but the unreserved characters and "/".
+func s3Escape(s string) string {
+	sb := &strings.Builder{}
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
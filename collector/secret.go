@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/kairos-io/kairos-sdk/schema"
+)
+
+const (
+	// SecretRefPrefix starts the values that are a reference to a secret, e.g.
+	// secret://file/etc/kairos/token or secret://env/NETWORK_TOKEN.
+	SecretRefPrefix = "secret://"
+	// RedactedValue replaces the sensitive values in a Redacted Config.
+	RedactedValue = "<redacted>"
+)
+
+var ErrSecretRef = errors.New("invalid secret reference")
+
+// resolveSecret returns the value a secret reference points to.
+func resolveSecret(ref string) (string, error) {
+	provider, location, _ := strings.Cut(strings.TrimPrefix(ref, SecretRefPrefix), "/")
+	if location == "" {
+		return "", fmt.Errorf("%w %q: missing location", ErrSecretRef, ref)
+	}
+
+	switch provider {
+	case "file":
+		dat, err := os.ReadFile("/" + location)
+		if err != nil {
+			return "", fmt.Errorf("%w %q: %w", ErrSecretRef, ref, err)
+		}
+		return strings.TrimRight(string(dat), "\r\n"), nil
+	case "env":
+		value, found := os.LookupEnv(location)
+		if !found {
+			return "", fmt.Errorf("%w %q: %s is not set", ErrSecretRef, ref, location)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("%w %q: unknown provider %q", ErrSecretRef, ref, provider)
+	}
+}
+
+// ResolveSecrets replaces all the secret references in the Config, at any
+// depth, with the value they point to. Scan already does it for the Config it
+// returns.
+func (c *Config) ResolveSecrets() error {
+	var errs error
+	var resolve func(v interface{}) interface{}
+	resolve = func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case string:
+			if !strings.HasPrefix(t, SecretRefPrefix) {
+				return t
+			}
+			value, err := resolveSecret(t)
+			if err != nil {
+				errs = errors.Join(errs, err)
+				return t
+			}
+			return value
+		case Config:
+			for k, item := range t {
+				t[k] = resolve(item)
+			}
+		case map[string]interface{}:
+			for k, item := range t {
+				t[k] = resolve(item)
+			}
+		case []interface{}:
+			for i, item := range t {
+				t[i] = resolve(item)
+			}
+		}
+		return v
+	}
+	resolve(*c)
+	return errs
+}
+
+var (
+	sensitivePathsOnce sync.Once
+	sensitivePaths     [][]string
+	sensitivePathsErr  error
+)
+
+// redactedPaths returns the paths of the values marked as sensitive in the
+// schema, split in segments.
+func redactedPaths() ([][]string, error) {
+	sensitivePathsOnce.Do(func() {
+		paths, err := schema.SensitivePaths(schema.RootSchema{})
+		if err != nil {
+			sensitivePathsErr = err
+			return
+		}
+		for _, p := range paths {
+			sensitivePaths = append(sensitivePaths, strings.Split(p, "."))
+		}
+	})
+	return sensitivePaths, sensitivePathsErr
+}
+
+// isSensitive returns true if path matches one of the sensitive paths, where
+// "*" matches any segment.
+func isSensitive(path []string, sensitive [][]string) bool {
+	for _, s := range sensitive {
+		if len(s) != len(path) {
+			continue
+		}
+		match := true
+		for i := range s {
+			if s[i] != "*" && s[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of the Config where the values marked as sensitive
+// in the schema, e.g. users passwords or the p2p network token, are replaced
+// by RedactedValue. If the schema can't be read, every value is redacted.
+func (c *Config) Redacted() *Config {
+	sensitive, err := redactedPaths()
+	all := err != nil
+
+	var redact func(v interface{}, path []string) interface{}
+	redact = func(v interface{}, path []string) interface{} {
+		if len(path) > 0 && isSensitive(path, sensitive) {
+			return RedactedValue
+		}
+		switch t := v.(type) {
+		case Config:
+			return Config(redact(map[string]interface{}(t), path).(map[string]interface{}))
+		case map[string]interface{}:
+			result := make(map[string]interface{}, len(t))
+			for k, item := range t {
+				result[k] = redact(item, append(path, k))
+			}
+			return result
+		case []interface{}:
+			result := make([]interface{}, len(t))
+			for i, item := range t {
+				result[i] = redact(item, append(path, fmt.Sprint(i)))
+			}
+			return result
+		}
+		if all {
+			return RedactedValue
+		}
+		return v
+	}
+
+	result := redact(*c, nil).(Config)
+	return &result
+}
+
+// StringRedacted is like String, but with the sensitive values redacted so
+// the Config can be logged or stored safely.
+func (c *Config) StringRedacted() (string, error) {
+	return c.Redacted().String()
+}
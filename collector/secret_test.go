@@ -0,0 +1,97 @@
+package collector_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Secrets", func() {
+	var tmpDir string
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "secrets")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "token"), []byte("s3cr3t\n"), os.ModePerm)).To(Succeed())
+		Expect(os.Setenv("KAIROS_TEST_PASSWD", "hunter2")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("KAIROS_TEST_PASSWD")).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("Scan", func() {
+		It("resolves secret references", func() {
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte(`#cloud-config
+users:
+- name: kairos
+  passwd: secret://env/KAIROS_TEST_PASSWD
+p2p:
+  network_token: secret://file`+filepath.Join(tmpDir, "token")+`
+`), os.ModePerm)).To(Succeed())
+
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir))).To(Succeed())
+			c, err := Scan(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*c)["users"].([]interface{})[0]).To(HaveKeyWithValue("passwd", "hunter2"))
+			Expect((*c)["p2p"]).To(HaveKeyWithValue("network_token", "s3cr3t"))
+		})
+
+		It("fails on references that can't be resolved", func() {
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte(`#cloud-config
+foo: secret://env/KAIROS_TEST_MISSING
+bar: secret://vault/foo
+`), os.ModePerm)).To(Succeed())
+
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir))).To(Succeed())
+			_, err := Scan(o, FilterKeysTest)
+			Expect(errors.Is(err, ErrSecretRef)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("KAIROS_TEST_MISSING is not set"))
+			Expect(err.Error()).To(ContainSubstring(`unknown provider "vault"`))
+		})
+	})
+
+	Describe("Redacted", func() {
+		c := Config{
+			"hostname": "foo",
+			"users": []interface{}{
+				Config{"name": "kairos", "passwd": "kairos"},
+			},
+			"p2p": Config{"network_token": "token", "network_id": "id"},
+			"install": Config{
+				"bundles": []interface{}{
+					Config{"targets": []interface{}{"run://quay.io/org/bundle"}, "auth": Config{"username": "user", "password": "pass"}},
+				},
+			},
+		}
+
+		It("redacts the sensitive values", func() {
+			r := c.Redacted()
+			Expect((*r)["hostname"]).To(Equal("foo"))
+			Expect((*r)["users"]).To(Equal([]interface{}{Config{"name": "kairos", "passwd": RedactedValue}}))
+			Expect((*r)["p2p"]).To(Equal(Config{"network_token": RedactedValue, "network_id": "id"}))
+			Expect(r.Query("install.bundles[0].auth")).To(Equal("password: <redacted>\nusername: user\n"))
+
+			By("not modifying the original")
+			Expect(c["p2p"]).To(HaveKeyWithValue("network_token", "token"))
+		})
+
+		It("renders without the sensitive values", func() {
+			s, err := c.StringRedacted()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s).To(HavePrefix(DefaultHeader))
+			Expect(s).To(ContainSubstring("network_id: id"))
+			Expect(s).ToNot(ContainSubstring("token\n"))
+			Expect(s).ToNot(ContainSubstring("passwd: kairos"))
+			Expect(s).ToNot(ContainSubstring("password: pass"))
+		})
+	})
+})
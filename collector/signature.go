@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignaturePolicy sets which configs must have a valid detached signature.
+// Files and config_url payloads without the required signature are skipped,
+// and recorded in ScanReport.Skipped, unless StrictValidation is set, which
+// makes Scan fail.
+type SignaturePolicy string
+
+const (
+	// SignaturePolicyNone doesn't verify any signature.
+	SignaturePolicyNone SignaturePolicy = ""
+	// SignaturePolicyVerify verifies the signature of the configs that have
+	// one and rejects them if it's not valid. Unsigned configs are accepted.
+	SignaturePolicyVerify SignaturePolicy = "verify"
+	// SignaturePolicyRequireRemote is like SignaturePolicyVerify, but configs
+	// downloaded from config_url must be signed.
+	SignaturePolicyRequireRemote SignaturePolicy = "require-remote"
+	// SignaturePolicyRequire requires every file and config_url to be signed.
+	SignaturePolicyRequire SignaturePolicy = "require"
+)
+
+// SignatureSuffix is appended to the path or URL of a config to find its
+// detached signature, as written by "cosign sign-blob --output-signature".
+const SignatureSuffix = ".sig"
+
+var (
+	ErrUnsignedConfig   = errors.New("config is not signed")
+	ErrInvalidSignature = errors.New("invalid config signature")
+)
+
+func (p SignaturePolicy) validate() error {
+	switch p {
+	case SignaturePolicyNone, SignaturePolicyVerify, SignaturePolicyRequireRemote, SignaturePolicyRequire:
+		return nil
+	}
+	return fmt.Errorf("unknown signature policy %q", p)
+}
+
+// requires returns true if the policy rejects unsigned configs of the kind.
+func (p SignaturePolicy) requires(kind SourceKind) bool {
+	return p == SignaturePolicyRequire || (p == SignaturePolicyRequireRemote && kind == SourceConfigURL)
+}
+
+// ParseVerificationKey parses a PEM encoded public key, as generated by
+// "cosign generate-key-pair", or x509 certificate. ECDSA, RSA and Ed25519
+// keys are supported.
+func ParseVerificationKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// verifySignature checks that the signature of the payload was made with one
+// of the keys. The signature can be raw or base64 encoded, as cosign does.
+func verifySignature(payload, signature []byte, keys []crypto.PublicKey) error {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+	digest := sha256.Sum256(payload)
+
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, digest[:], signature) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil ||
+				rsa.VerifyPSS(k, crypto.SHA256, digest[:], signature, nil) == nil {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, signature) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// checkSignature applies the signature policy to the payload of a source,
+// given the result of reading its signature. Keys set in the config that
+// pointed to the source, if any, make the signature mandatory.
+func (s *scanState) checkSignature(kind SourceKind, payload []byte, fetchSignature func() ([]byte, error), configKeys []crypto.PublicKey) error {
+	if s.signaturePolicy == SignaturePolicyNone && len(configKeys) == 0 {
+		return nil
+	}
+
+	signature, err := fetchSignature()
+	if err != nil {
+		if s.signaturePolicy.requires(kind) || len(configKeys) > 0 {
+			return fmt.Errorf("%w: %w", ErrUnsignedConfig, err)
+		}
+		return nil
+	}
+
+	return verifySignature(payload, signature, append(append([]crypto.PublicKey{}, s.signatureKeys...), configKeys...))
+}
+
+// cosignKeys returns the key set in cosign-key if cosign is enabled in the
+// Config. The key can be inline PEM, a path or a URL downloaded with fetch.
+func (c Config) cosignKeys(fetch func(u string) ([]byte, error)) ([]crypto.PublicKey, error) {
+	enabled, _ := c["cosign"].(bool)
+	ref, _ := c["cosign-key"].(string)
+	if !enabled || ref == "" {
+		return nil, nil
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(ref), "-----BEGIN"):
+		data = []byte(ref)
+	case strings.Contains(ref, "://"):
+		data, err = fetch(ref)
+	default:
+		data, err = os.ReadFile(ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cosign-key: %w", err)
+	}
+
+	key, err := ParseVerificationKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading cosign-key: %w", err)
+	}
+	return []crypto.PublicKey{key}, nil
+}
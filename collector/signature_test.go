@@ -0,0 +1,189 @@
+package collector_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signed configs", func() {
+	var tmpDir, serverDir, keyFile string
+	var key *ecdsa.PrivateKey
+	var closeFunc ServerCloseFunc
+	var port int
+	var err error
+
+	url := func(file string) string {
+		return fmt.Sprintf("http://127.0.0.1:%d/%s", port, file)
+	}
+	// sign writes the content to the file and, as cosign sign-blob does, its
+	// base64 encoded signature next to it.
+	sign := func(file, content string) {
+		Expect(os.WriteFile(file, []byte(content), os.ModePerm)).To(Succeed())
+		digest := sha256.Sum256([]byte(content))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(file+SignatureSuffix, []byte(base64.StdEncoding.EncodeToString(sig)), os.ModePerm)).To(Succeed())
+	}
+	scan := func(opts ...Option) (*Config, *ScanReport, error) {
+		o := &Options{}
+		Expect(o.Apply(append([]Option{NoLogs, Directories(tmpDir)}, opts...)...)).To(Succeed())
+		return ScanWithReport(o, FilterKeysTest)
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "signed")
+		Expect(err).ToNot(HaveOccurred())
+		serverDir, err = os.MkdirTemp("", "signed_remote")
+		Expect(err).ToNot(HaveOccurred())
+		closeFunc, port, err = startAssetServer(serverDir)
+		Expect(err).ToNot(HaveOccurred())
+		RegisterFetcher("http", &HTTPFetcher{Attempts: 1})
+
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		Expect(err).ToNot(HaveOccurred())
+		keyFile = filepath.Join(serverDir, "cosign.pub")
+		Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), os.ModePerm)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		RegisterFetcher("http", &HTTPFetcher{})
+		closeFunc()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		Expect(os.RemoveAll(serverDir)).To(Succeed())
+	})
+
+	Describe("files", func() {
+		BeforeEach(func() {
+			sign(filepath.Join(tmpDir, "signed.yaml"), "#cloud-config\nsigned: true\n")
+			Expect(os.WriteFile(filepath.Join(tmpDir, "unsigned.yaml"), []byte("#cloud-config\nunsigned: true\n"), os.ModePerm)).To(Succeed())
+			sign(filepath.Join(tmpDir, "tampered.yaml"), "#cloud-config\ntampered: false\n")
+			Expect(os.WriteFile(filepath.Join(tmpDir, "tampered.yaml"), []byte("#cloud-config\ntampered: true\n"), os.ModePerm)).To(Succeed())
+		})
+
+		It("rejects files with invalid signatures", func() {
+			c, report, err := scan(VerifySignatures(SignaturePolicyVerify, keyFile))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(Equal(Config{"signed": true, "unsigned": true}))
+			Expect(report.Skipped).To(ContainElement(SkippedSource{
+				Source: Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "tampered.yaml")},
+				Reason: "signature: " + ErrInvalidSignature.Error(),
+			}))
+		})
+
+		It("fails on files with invalid signatures with StrictValidation", func() {
+			_, _, err := scan(VerifySignatures(SignaturePolicyVerify, keyFile), StrictValidation(true))
+			var verrs ValidationErrors
+			Expect(errors.As(err, &verrs)).To(BeTrue())
+			Expect(verrs).To(ContainElement(And(
+				HaveField("Source", Source{Kind: SourceFile, Location: filepath.Join(tmpDir, "tampered.yaml")}),
+				HaveField("Message", ErrInvalidSignature.Error()),
+			)))
+		})
+
+		It("rejects unsigned files if required", func() {
+			c, _, err := scan(VerifySignatures(SignaturePolicyRequire, keyFile))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(Equal(Config{"signed": true}))
+		})
+
+		It("doesn't verify anything by default", func() {
+			c, _, err := scan()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(HaveKeyWithValue("tampered", true))
+		})
+
+		It("accepts x509 certificates", func() {
+			template := &x509.Certificate{
+				SerialNumber: big.NewInt(1),
+				Subject:      pkix.Name{CommonName: "kairos"},
+				NotBefore:    time.Now(),
+				NotAfter:     time.Now().Add(time.Hour),
+			}
+			der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+			Expect(err).ToNot(HaveOccurred())
+			certFile := filepath.Join(serverDir, "cert.pem")
+			Expect(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), os.ModePerm)).To(Succeed())
+
+			c, _, err := scan(VerifySignatures(SignaturePolicyRequire, certFile))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(Equal(Config{"signed": true}))
+		})
+	})
+
+	Describe("config_url", func() {
+		It("rejects unsigned remote configs if required", func() {
+			Expect(os.WriteFile(filepath.Join(serverDir, "remote.yaml"), []byte("#cloud-config\nremote: true\n"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\nconfig_url: "+url("remote.yaml")+"\n"), os.ModePerm)).To(Succeed())
+
+			c, _, err := scan(VerifySignatures(SignaturePolicyVerify, keyFile))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(HaveKeyWithValue("remote", true))
+
+			c, report, err := scan(VerifySignatures(SignaturePolicyRequireRemote, keyFile))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).ToNot(HaveKey("remote"))
+			Expect(*c).To(HaveKey("config_url"))
+			Expect(report.Skipped).To(ContainElement(And(
+				HaveField("Source", Source{Kind: SourceConfigURL, Location: url("remote.yaml")}),
+				HaveField("Reason", HavePrefix("signature: "+ErrUnsignedConfig.Error())),
+			)))
+
+			_, _, err = scan(VerifySignatures(SignaturePolicyRequireRemote, keyFile), StrictValidation(true))
+			var verrs ValidationErrors
+			Expect(errors.As(err, &verrs)).To(BeTrue())
+			Expect(verrs[0].Source).To(Equal(Source{Kind: SourceConfigURL, Location: url("remote.yaml")}))
+			Expect(verrs[0].Message).To(ContainSubstring(ErrUnsignedConfig.Error()))
+		})
+
+		It("verifies remote configs with the cosign-key of the config", func() {
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte(fmt.Sprintf(`#cloud-config
+cosign: true
+cosign-key: %s
+config_url: %s
+`, url("cosign.pub"), url("remote.yaml"))), os.ModePerm)).To(Succeed())
+
+			sign(filepath.Join(serverDir, "remote.yaml"), "#cloud-config\nremote: true\n")
+			c, _, err := scan()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).To(HaveKeyWithValue("remote", true))
+
+			Expect(os.WriteFile(filepath.Join(serverDir, "remote.yaml"), []byte("#cloud-config\nremote: mitm\n"), os.ModePerm)).To(Succeed())
+			c, report, err := scan()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).ToNot(HaveKey("remote"))
+			Expect(*c).To(HaveKeyWithValue("cosign", true))
+			Expect(report.Skipped).To(ContainElement(SkippedSource{
+				Source: Source{Kind: SourceConfigURL, Location: url("remote.yaml")},
+				Reason: "signature: " + ErrInvalidSignature.Error(),
+			}))
+			_, _, err = scan(StrictValidation(true))
+			Expect(err).To(MatchError(ContainSubstring(ErrInvalidSignature.Error())))
+
+			Expect(os.Remove(filepath.Join(serverDir, "remote.yaml"+SignatureSuffix))).To(Succeed())
+			c, report, err = scan()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).ToNot(HaveKey("remote"))
+			Expect(report.Skipped).To(ContainElement(HaveField("Reason", HavePrefix("signature: "+ErrUnsignedConfig.Error()))))
+			_, _, err = scan(StrictValidation(true))
+			Expect(err).To(MatchError(ContainSubstring(ErrUnsignedConfig.Error())))
+		})
+	})
+})
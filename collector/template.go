@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/joho/godotenv"
+	"github.com/kairos-io/kairos-sdk/ghw"
+	"github.com/kairos-io/kairos-sdk/machine"
+	"github.com/kairos-io/kairos-sdk/types"
+)
+
+// TemplateData holds the facts about the running system available to configs
+// rendered with RenderTemplates, e.g. {{ .UUID }} or {{ .BootState }}.
+type TemplateData struct {
+	UUID      string
+	BootState string
+	// Runtime holds the state.Runtime of the system as in its YAML
+	// representation, e.g. {{ .Runtime.kairos.flavor }}. It's only
+	// available on Linux.
+	Runtime map[string]interface{}
+	Disks   []*types.Disk
+	// MACs maps the name of every network interface to its hardware address.
+	MACs map[string]string
+	// OSRelease holds the values of /etc/os-release.
+	OSRelease map[string]string
+}
+
+// NewTemplateData gathers the facts of the running system. It's best-effort,
+// facts that can't be detected are left empty.
+func NewTemplateData(logger types.KairosLogger) *TemplateData {
+	osRelease, err := godotenv.Read("/etc/os-release")
+	if err != nil {
+		logger.Logger.Warn().Err(err).Msg("reading os-release")
+	}
+
+	data := &TemplateData{
+		UUID:      machine.UUID(),
+		BootState: "unknown",
+		Disks:     ghw.GetDisks(ghw.NewPaths(""), &logger),
+		MACs:      machine.MACs(),
+		OSRelease: osRelease,
+	}
+	detectRuntime(logger, data)
+	return data
+}
+
+// templateFuncs is the set of functions available to templates. It's kept
+// small on purpose: templates can only transform the data they are given.
+var templateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": func(from, to, s string) string { return strings.ReplaceAll(s, from, to) },
+	"join":    func(sep string, s []string) string { return strings.Join(s, sep) },
+	// trunc keeps the first n characters of s or, if n is negative, the last ones.
+	"trunc": func(n int, s string) string {
+		switch {
+		case n >= 0 && len(s) > n:
+			return s[:n]
+		case n < 0 && len(s) > -n:
+			return s[len(s)+n:]
+		}
+		return s
+	},
+	"default": func(d, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return d
+		}
+		return v
+	},
+	"largestDisk": func(disks []*types.Disk) *types.Disk {
+		var largest *types.Disk
+		for _, d := range disks {
+			if largest == nil || d.SizeBytes > largest.SizeBytes {
+				largest = d
+			}
+		}
+		return largest
+	},
+	"smallestDisk": func(disks []*types.Disk) *types.Disk {
+		var smallest *types.Disk
+		for _, d := range disks {
+			if smallest == nil || d.SizeBytes < smallest.SizeBytes {
+				smallest = d
+			}
+		}
+		return smallest
+	},
+}
+
+// RenderTemplate renders content as a Go template with the given data.
+func RenderTemplate(content []byte, data *TemplateData) ([]byte, error) {
+	tmpl, err := template.New("config").Option("missingkey=error").Funcs(templateFuncs).Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// render returns the content of the source rendered as a template if
+// RenderTemplates is set, gathering the TemplateData on first use.
+func (s *scanState) render(content []byte) ([]byte, error) {
+	if !s.templates || !bytes.Contains(content, []byte("{{")) {
+		return content, nil
+	}
+	if s.templateData == nil {
+		s.templateData = NewTemplateData(s.logger)
+	}
+	return RenderTemplate(content, s.templateData)
+}
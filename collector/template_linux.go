@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"github.com/kairos-io/kairos-sdk/state"
+	"github.com/kairos-io/kairos-sdk/types"
+	"gopkg.in/yaml.v3"
+)
+
+// detectRuntime fills the TemplateData with the state.Runtime of the system.
+func detectRuntime(logger types.KairosLogger, data *TemplateData) {
+	runtime, err := state.NewRuntimeWithLogger(logger.Logger)
+	if err != nil {
+		logger.Logger.Warn().Err(err).Msg("detecting runtime state")
+	}
+	// Keep the machine ID based UUID if the runtime state has none.
+	if runtime.UUID != "" {
+		data.UUID = runtime.UUID
+	}
+	data.BootState = string(runtime.BootState)
+
+	if err := yaml.Unmarshal([]byte(runtime.String()), &data.Runtime); err != nil {
+		logger.Logger.Warn().Err(err).Msg("converting runtime state")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package collector
+
+import "github.com/kairos-io/kairos-sdk/types"
+
+// detectRuntime does nothing as state.Runtime can only be detected on Linux.
+func detectRuntime(_ types.KairosLogger, _ *TemplateData) {}
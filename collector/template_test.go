@@ -0,0 +1,103 @@
+package collector_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	"github.com/kairos-io/kairos-sdk/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Templates", func() {
+	data := TemplateData{
+		UUID:      "0123456789abcdef",
+		BootState: "active_boot",
+		Runtime:   map[string]interface{}{"kairos": map[string]interface{}{"flavor": "opensuse"}},
+		Disks: []*types.Disk{
+			{Name: "/dev/sda", SizeBytes: 10},
+			{Name: "/dev/nvme0n1", SizeBytes: 500},
+			{Name: "/dev/sdb", SizeBytes: 50},
+		},
+		MACs:      map[string]string{"eth0": "52:54:00:12:34:56"},
+		OSRelease: map[string]string{"KAIROS_FLAVOR": "alpine"},
+	}
+
+	Describe("RenderTemplate", func() {
+		It("renders runtime facts", func() {
+			out, err := RenderTemplate([]byte(`#cloud-config
+hostname: node-{{ .UUID | trunc 8 }}
+boot: {{ .BootState }}
+install:
+  device: {{ (largestDisk .Disks).Name }}
+mac: {{ index .MACs "eth0" | replace ":" "" }}
+flavor: {{ .OSRelease.KAIROS_FLAVOR | upper }}
+runtime: {{ .Runtime.kairos.flavor }}
+`), &data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(out)).To(Equal(`#cloud-config
+hostname: node-01234567
+boot: active_boot
+install:
+  device: /dev/nvme0n1
+mac: 525400123456
+flavor: ALPINE
+runtime: opensuse
+`))
+		})
+
+		It("fails on missing keys", func() {
+			_, err := RenderTemplate([]byte(`{{ .OSRelease.MISSING }}`), &data)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Scan", func() {
+		var tmpDir string
+		var err error
+
+		BeforeEach(func() {
+			tmpDir, err = os.MkdirTemp("", "templates")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("#cloud-config\nhostname: node-{{ .UUID | trunc 4 }}\n"), os.ModePerm)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		It("renders files and readers", func() {
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir), WithTemplateData(data),
+				Readers(strings.NewReader(`smallest: "{{ (smallestDisk .Disks).Name }}"`)),
+			)).To(Succeed())
+
+			c, err := Scan(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*c)["hostname"]).To(Equal("node-0123"))
+			Expect((*c)["smallest"]).To(Equal("/dev/sda"))
+		})
+
+		It("skips configs that can't be rendered", func() {
+			Expect(os.WriteFile(filepath.Join(tmpDir, "broken.yaml"), []byte("#cloud-config\nfoo: {{ .Nope }}\n"), os.ModePerm)).To(Succeed())
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir), WithTemplateData(data))).To(Succeed())
+
+			c, report, err := ScanWithReport(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*c).ToNot(HaveKey("foo"))
+			Expect(report.Skipped).To(ConsistOf(HaveField("Reason", HavePrefix("invalid template:"))))
+		})
+
+		It("does not render unless enabled", func() {
+			o := &Options{}
+			Expect(o.Apply(NoLogs, Directories(tmpDir))).To(Succeed())
+
+			c, err := Scan(o, FilterKeysTest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect((*c)["hostname"]).To(Equal("node-{{ .UUID | trunc 4 }}"))
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kairos-io/kairos-sdk/schema"
+)
+
+// ValidationError is a violation of the Kairos schema found when scanning
+// with StrictValidation. The ValidationResult is located in the Source.
+type ValidationError struct {
+	// Source is the source which set the offending value.
+	Source Source
+	schema.ValidationResult
+}
+
+func (e ValidationError) Error() string {
+	location := e.Source.String()
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Line)
+	}
+	result := e.ValidationResult
+	result.Line, result.Column = 0, 0
+	return fmt.Sprintf("%s: %s", location, result)
+}
+
+// ValidationErrors is returned by Scan when StrictValidation is set and either
+// a source or the merged config are not valid.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid configuration:\n%s", strings.Join(lines, "\n"))
+}
+
+// schemaResults validates the config against schema.RootSchema, see
+// schema.KConfig.Results. If partial is true, missing required keys are
+// ignored as the config is only one of the sources that will be merged.
+func schemaResults(c *Config, partial bool) (schema.ValidationResults, error) {
+	data, err := c.String()
+	if err != nil {
+		return nil, err
+	}
+	kc, err := schema.NewConfigFromYAML(data, schema.RootSchema{})
+	if err != nil {
+		return nil, err
+	}
+	if partial {
+		return kc.PartialResults()
+	}
+	return kc.Results()
+}
+
+// locateIn sets the line and column of the result to the ones of its path in
+// the YAML content, or clears them if the content is unknown.
+func locateIn(content []byte, result schema.ValidationResult) schema.ValidationResult {
+	result.Line, result.Column = 0, 0
+	if content != nil {
+		var path []string
+		if result.Path != "" {
+			path = strings.Split(result.Path, ".")
+		}
+		result.Line, result.Column = schema.Locate(content, path)
+	}
+	return result
+}
+
+// validateSource checks a single source against the schema, ignoring missing
+// required keys, and records its content to locate later violations.
+func (s *scanState) validateSource(source Source, content []byte, c *Config) {
+	s.contents[source] = content
+	if !s.strict {
+		return
+	}
+
+	results, err := schemaResults(c, true)
+	if err != nil {
+		s.addInvalid(ValidationError{Source: source, ValidationResult: schema.ValidationResult{Message: err.Error()}})
+		return
+	}
+	for _, r := range results {
+		s.addInvalid(ValidationError{Source: source, ValidationResult: locateIn(content, r)})
+	}
+}
+
+// addInvalid records the error unless it was already found, which happens when
+// a violation in a source is also present in the merged config.
+func (s *scanState) addInvalid(verr ValidationError) {
+	for _, e := range s.invalid {
+		if e == verr {
+			return
+		}
+	}
+	s.invalid = append(s.invalid, verr)
+}
+
+// validateResult checks the merged config against the schema and returns all
+// the errors found in it and in the individual sources, if any.
+func (s *scanState) validateResult(c *Config) error {
+	if !s.strict {
+		return nil
+	}
+
+	results, err := schemaResults(c, false)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if kp, err := s.report.Provenance.Query(r.Path); err == nil && r.Path != "" {
+			s.addInvalid(ValidationError{Source: kp.Source, ValidationResult: locateIn(s.contents[kp.Source], r)})
+		} else {
+			r.Line, r.Column = 0, 0
+			s.addInvalid(ValidationError{Source: Source{Kind: SourceMerged}, ValidationResult: r})
+		}
+	}
+
+	if len(s.invalid) == 0 {
+		return nil
+	}
+	for _, v := range s.invalid {
+		s.logger.Logger.Error().
+			Str("kind", string(v.Source.Kind)).
+			Str("source", v.Source.Location).
+			Int("line", v.Line).
+			Str("path", v.Path).
+			Msg(v.Message)
+	}
+	return s.invalid
+}
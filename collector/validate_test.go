@@ -0,0 +1,116 @@
+package collector_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StrictValidation", func() {
+	var tmpDir string
+	var err error
+
+	write := func(name, content string) string {
+		f := filepath.Join(tmpDir, name)
+		Expect(os.WriteFile(f, []byte(content), os.ModePerm)).To(Succeed())
+		return f
+	}
+	scan := func(strict bool) (*Config, error) {
+		o := &Options{}
+		Expect(o.Apply(NoLogs, Directories(tmpDir), StrictValidation(strict))).To(Succeed())
+		return Scan(o, FilterKeysTest)
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "strict")
+		Expect(err).ToNot(HaveOccurred())
+		write("00_users.yaml", `#cloud-config
+users:
+- name: kairos
+  passwd: kairos
+`)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("accepts valid configs", func() {
+		write("01_install.yaml", "#cloud-config\ninstall:\n  device: /dev/sda\n")
+		_, err := scan(true)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("names the file and line of invalid values", func() {
+		f := write("01_install.yaml", `#cloud-config
+install:
+  auto: true
+  device: 5
+`)
+		_, err := scan(false)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = scan(true)
+		var verrs ValidationErrors
+		Expect(errors.As(err, &verrs)).To(BeTrue())
+		Expect(verrs).To(HaveLen(1))
+		Expect(verrs[0].Source).To(Equal(Source{Kind: SourceFile, Location: f}))
+		Expect(verrs[0].Path).To(Equal("install.device"))
+		Expect(verrs[0].Line).To(Equal(4))
+		Expect(err.Error()).To(ContainSubstring(f + ":4: install.device:"))
+	})
+
+	It("validates the merged result", func() {
+		write("01_reboot.yaml", "#cloud-config\ninstall:\n  reboot: true\n")
+		f := write("02_poweroff.yaml", "#cloud-config\ninstall:\n  poweroff: true\n")
+
+		_, err := scan(true)
+		var verrs ValidationErrors
+		Expect(errors.As(err, &verrs)).To(BeTrue())
+		Expect(verrs).To(HaveLen(1))
+		Expect(verrs[0].Source).To(Equal(Source{Kind: SourceFile, Location: f}))
+		Expect(verrs[0].Path).To(Equal("install.poweroff"))
+		Expect(verrs[0].Line).To(Equal(3))
+	})
+
+	It("reports missing keys of the merged result", func() {
+		Expect(os.Remove(filepath.Join(tmpDir, "00_users.yaml"))).To(Succeed())
+		write("01_user.yaml", "#cloud-config\nuser:\n- name: kairos\n")
+
+		_, err := scan(true)
+		var verrs ValidationErrors
+		Expect(errors.As(err, &verrs)).To(BeTrue())
+		Expect(verrs).To(HaveLen(1))
+		Expect(verrs[0].Source.Kind).To(Equal(SourceMerged))
+		Expect(verrs[0].Suggestion).To(Equal(`did you mean "users" instead of "user"?`))
+	})
+
+	It("reports the same violations as the schema, with suggestions", func() {
+		f := write("01_p2p.yaml", "#cloud-config\np2p:\n  network_token: token\n  role: mastr\n")
+
+		_, err := scan(true)
+		var verrs ValidationErrors
+		Expect(errors.As(err, &verrs)).To(BeTrue())
+		Expect(verrs).To(HaveLen(1))
+		Expect(verrs[0].Source).To(Equal(Source{Kind: SourceFile, Location: f}))
+		Expect(verrs[0].Line).To(Equal(4))
+		Expect(verrs[0].Suggestion).To(Equal(`did you mean "master"?`))
+		Expect(err.Error()).To(ContainSubstring(f + `:4: p2p.role: value must be one of "master", "worker", "none" (did you mean "master"?)`))
+	})
+
+	It("rejects files with invalid YAML", func() {
+		f := write("01_broken.yaml", "#cloud-config\ninstall:\n  device: [\n")
+
+		c, err := scan(false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*c).ToNot(HaveKey("install"))
+
+		_, err = scan(true)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(f))
+	})
+})
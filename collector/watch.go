@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWatchUnsupported is returned by Watch on platforms without inotify.
+var ErrWatchUnsupported = errors.New("watching directories is not supported on this platform")
+
+// watchDebounce is the time Watch waits for more changes before scanning, as
+// writing a single file usually triggers several events.
+const watchDebounce = 200 * time.Millisecond
+
+// WatchEvent is sent by Watch every time the merged config changes. If the
+// scan failed, Err is set and Config holds whatever could be merged.
+type WatchEvent struct {
+	Config *Config
+	Diff   *ConfigDiff
+	Err    error
+}
+
+// Watch monitors the ScanDir directories and scans them again every time a
+// file changes, sending the new Config and its Diff with the previous one on
+// the returned channel. The first event holds the initial Config. The channel is
+// closed when ctx is done. Files in ScanDir are watched too. Directories that
+// don't exist yet, like /oem before it's mounted, are watched once they are
+// created. The configs are filtered
+// with the Filter set with WithFilter, if any.
+func Watch(ctx context.Context, opts ...Option) (<-chan WatchEvent, error) {
+	o := &Options{}
+	if err := o.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	// Start watching before the first scan so no change is missed.
+	changed, err := watchDirs(ctx, o.ScanDir, o.logger())
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+
+		current := &Config{}
+		send := func(initial bool) bool {
+			c, err := Scan(o, o.Filter)
+			if err != nil {
+				return sendEvent(ctx, events, WatchEvent{Config: c, Err: err})
+			}
+			diff := Diff(current, c)
+			if diff.Empty() && !initial {
+				return true
+			}
+			current = c
+			return sendEvent(ctx, events, WatchEvent{Config: c, Diff: diff})
+		}
+
+		if !send(true) {
+			return
+		}
+		for debounce(ctx, changed) {
+			if !send(false) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func sendEvent(ctx context.Context, events chan<- WatchEvent, e WatchEvent) bool {
+	select {
+	case events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// debounce waits for a change and then until no more changes happen for
+// watchDebounce. It returns false if ctx is done or the watcher stopped.
+func debounce(ctx context.Context, changed <-chan struct{}) bool {
+	select {
+	case _, ok := <-changed:
+		if !ok {
+			return false
+		}
+	case <-ctx.Done():
+		return false
+	}
+
+	timer := time.NewTimer(watchDebounce)
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-changed:
+			if !ok {
+				return false
+			}
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
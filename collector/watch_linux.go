@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/kairos-io/kairos-sdk/types"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+// watchDirs watches the directories and their subdirectories with inotify and
+// signals on the returned channel when something changes in them. Entries that
+// are files are watched through their directory, only their own changes being
+// signaled. For the entries that don't exist, the nearest existing parent is
+// watched until they are created. The channel is closed when ctx is done.
+func watchDirs(ctx context.Context, dirs []string, logger types.KairosLogger) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// As the descriptor is non-blocking, closing the file unblocks Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	// watched maps the watch descriptors to their directory, to watch new
+	// subdirectories too.
+	watched := map[int32]string{}
+	addTree := func(dir string) {
+		_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			wd, err := unix.InotifyAddWatch(fd, path, inotifyMask)
+			if err != nil {
+				logger.Logger.Warn().Err(err).Str("dir", path).Msg("couldn't watch directory")
+				return nil
+			}
+			watched[int32(wd)] = path
+			return nil
+		})
+	}
+	// files maps the watch descriptors of the directories holding file
+	// entries to the names of those files.
+	files := map[int32]map[string]bool{}
+	addFile := func(file string) {
+		dir := filepath.Dir(file)
+		wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			logger.Logger.Warn().Err(err).Str("dir", dir).Msg("couldn't watch directory")
+			return
+		}
+		if files[int32(wd)] == nil {
+			files[int32(wd)] = map[string]bool{}
+		}
+		files[int32(wd)][filepath.Base(file)] = true
+	}
+	// add watches the entry as a tree if it's a directory or as a file
+	// otherwise, returning false if it doesn't exist.
+	add := func(entry string) bool {
+		info, err := os.Stat(entry)
+		switch {
+		case err != nil:
+			return false
+		case info.IsDir():
+			addTree(entry)
+		default:
+			addFile(entry)
+		}
+		return true
+	}
+	// missing are the entries that don't exist yet, and parents the
+	// directories watched only to find out when they are created.
+	missing := map[string]bool{}
+	parents := map[int32]string{}
+	// watchMissing watches the missing entries that exist now, returning
+	// true if any does, and the nearest existing parent of the rest.
+	watchMissing := func() bool {
+		created := false
+		for d := range missing {
+			if add(d) {
+				delete(missing, d)
+				created = true
+				continue
+			}
+			parent := filepath.Dir(d)
+			for parent != filepath.Dir(parent) {
+				if _, err := os.Stat(parent); err == nil {
+					break
+				}
+				parent = filepath.Dir(parent)
+			}
+			wd, err := unix.InotifyAddWatch(fd, parent, inotifyMask)
+			if err != nil {
+				logger.Logger.Warn().Err(err).Str("dir", parent).Msg("couldn't watch directory")
+				continue
+			}
+			if _, found := watched[int32(wd)]; !found {
+				parents[int32(wd)] = parent
+			}
+		}
+		if len(missing) == 0 {
+			for wd := range parents {
+				_, inTree := watched[wd]
+				if _, holdsFiles := files[wd]; !inTree && !holdsFiles {
+					_, _ = unix.InotifyRmWatch(fd, uint32(wd))
+				}
+				delete(parents, wd)
+			}
+		}
+		return created
+	}
+	for _, d := range dirs {
+		if !add(d) {
+			missing[filepath.Clean(d)] = true
+		}
+	}
+	watchMissing()
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer close(changed)
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.PathMax))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			// Only changes in the watched trees and files, or new entries,
+			// are signaled.
+			relevant, parentChanged := false, false
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameStart := offset + unix.SizeofInotifyEvent
+				offset = nameStart + int(event.Len)
+				name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+				dir, inTree := watched[event.Wd]
+				relevant = relevant || inTree || files[event.Wd][name]
+				if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) == 0 {
+					continue
+				}
+				if _, found := parents[event.Wd]; found {
+					parentChanged = true
+				}
+				if inTree && event.Mask&unix.IN_ISDIR != 0 {
+					addTree(filepath.Join(dir, name))
+				}
+			}
+			if parentChanged && len(missing) > 0 && watchMissing() {
+				relevant = true
+			}
+			if !relevant {
+				continue
+			}
+
+			// The channel is buffered, so pending changes are coalesced.
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changed, nil
+}
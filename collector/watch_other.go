@@ -0,0 +1,13 @@
+//go:build !linux
+
+package collector
+
+import (
+	"context"
+
+	"github.com/kairos-io/kairos-sdk/types"
+)
+
+func watchDirs(_ context.Context, _ []string, _ types.KairosLogger) (<-chan struct{}, error) {
+	return nil, ErrWatchUnsupported
+}
@@ -0,0 +1,127 @@
+package collector_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/kairos-io/kairos-sdk/collector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watch", func() {
+	var tmpDir string
+	var err error
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	write := func(name, content string) {
+		f := filepath.Join(tmpDir, name)
+		Expect(os.MkdirAll(filepath.Dir(f), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(f, []byte(content), os.ModePerm)).To(Succeed())
+	}
+	next := func(events <-chan WatchEvent) WatchEvent {
+		var e WatchEvent
+		EventuallyWithOffset(1, events, 5*time.Second).Should(Receive(&e))
+		ExpectWithOffset(1, e.Err).ToNot(HaveOccurred())
+		return e
+	}
+
+	// writeUntilEvent writes the file until Watch sends an event, as new
+	// directories are watched asynchronously.
+	writeUntilEvent := func(events <-chan WatchEvent, name, content string) WatchEvent {
+		var e WatchEvent
+		EventuallyWithOffset(1, func(g Gomega) {
+			write(name, content)
+			g.Expect(events).To(Receive(&e))
+		}).WithTimeout(5 * time.Second).WithPolling(500 * time.Millisecond).Should(Succeed())
+		ExpectWithOffset(1, e.Err).ToNot(HaveOccurred())
+		return e
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = os.MkdirTemp("", "watch")
+		Expect(err).ToNot(HaveOccurred())
+		write("00_config.yaml", "#cloud-config\nhostname: foo\ninstall:\n  device: /dev/sda\n")
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("sends the config every time it changes", func() {
+		events, err := Watch(ctx, NoLogs, WithFilter(FilterKeysTest), Directories(tmpDir))
+		Expect(err).ToNot(HaveOccurred())
+
+		e := next(events)
+		Expect((*e.Config)["hostname"]).To(Equal("foo"))
+		Expect(e.Diff.Added).To(Equal([]KeyChange{
+			{Path: "hostname", New: "foo"},
+			{Path: "install.device", New: "/dev/sda"},
+		}))
+
+		write("00_config.yaml", "#cloud-config\nhostname: bar\n")
+		e = next(events)
+		Expect((*e.Config)["hostname"]).To(Equal("bar"))
+		Expect(e.Diff).To(Equal(&ConfigDiff{
+			Removed: []KeyChange{{Path: "install.device", Old: "/dev/sda"}},
+			Changed: []KeyChange{{Path: "hostname", Old: "foo", New: "bar"}},
+		}))
+
+		By("watching new subdirectories")
+		Expect(os.Mkdir(filepath.Join(tmpDir, "sub"), os.ModePerm)).To(Succeed())
+		e = writeUntilEvent(events, "sub/01_config.yaml", "#cloud-config\nfoo: bar\n")
+		Expect(e.Diff).To(Equal(&ConfigDiff{Added: []KeyChange{{Path: "foo", New: "bar"}}}))
+
+		By("ignoring changes that don't change the config")
+		write("ignored.txt", "foo")
+		Consistently(events, time.Second).ShouldNot(Receive())
+	})
+
+	It("watches directories created after it starts", func() {
+		events, err := Watch(ctx, NoLogs, WithFilter(FilterKeysTest), Directories(filepath.Join(tmpDir, "oem", "config")))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*next(events).Config).To(BeEmpty())
+
+		By("ignoring changes outside of the directories")
+		write("other/config.yaml", "#cloud-config\nhostname: other\n")
+		Consistently(events, time.Second).ShouldNot(Receive())
+
+		Expect(os.MkdirAll(filepath.Join(tmpDir, "oem", "config"), os.ModePerm)).To(Succeed())
+		e := writeUntilEvent(events, "oem/config/config.yaml", "#cloud-config\nhostname: oem\n")
+		Expect(e.Diff).To(Equal(&ConfigDiff{Added: []KeyChange{{Path: "hostname", New: "oem"}}}))
+	})
+
+	It("watches the files given as directories", func() {
+		write("01_other.yaml", "#cloud-config\nhostname: other\n")
+		events, err := Watch(ctx, NoLogs, WithFilter(FilterKeysTest), Directories(filepath.Join(tmpDir, "00_config.yaml")))
+		Expect(err).ToNot(HaveOccurred())
+		Expect((*next(events).Config)["hostname"]).To(Equal("foo"))
+
+		By("ignoring the other files of the directory")
+		write("01_other.yaml", "#cloud-config\nhostname: changed\n")
+		Consistently(events, time.Second).ShouldNot(Receive())
+
+		e := writeUntilEvent(events, "00_config.yaml", "#cloud-config\nhostname: bar\ninstall:\n  device: /dev/sda\n")
+		Expect(e.Diff).To(Equal(&ConfigDiff{Changed: []KeyChange{{Path: "hostname", Old: "foo", New: "bar"}}}))
+	})
+
+	It("scans without a filter by default", func() {
+		events, err := Watch(ctx, NoLogs, Directories(tmpDir))
+		Expect(err).ToNot(HaveOccurred())
+		Expect((*next(events).Config)["hostname"]).To(Equal("foo"))
+	})
+
+	It("closes the channel when the context is done", func() {
+		events, err := Watch(ctx, NoLogs, WithFilter(FilterKeysTest), Directories(tmpDir))
+		Expect(err).ToNot(HaveOccurred())
+		next(events)
+
+		cancel()
+		Eventually(events).Should(BeClosed())
+	})
+})
@@ -1,7 +1,9 @@
 package machine
 
 import (
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/google/shlex"
@@ -9,21 +11,34 @@ import (
 )
 
 func DotToYAML(file string) ([]byte, error) {
+	v, err := CmdlineToDot(file)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return unstructured.ToYAML(v)
+}
+
+// CmdlineToDot reads the cmdline file, /proc/cmdline by default, and returns
+// its keys in dot notation, as accepted by unstructured.ToYAML.
+func CmdlineToDot(file string) (map[string]interface{}, error) {
 	if file == "" {
 		file = "/proc/cmdline"
 	}
 	dat, err := os.ReadFile(file)
 	if err != nil {
-		return []byte{}, err
+		return map[string]interface{}{}, err
 	}
 
-	v := stringToMap(string(dat))
-
-	return unstructured.ToYAML(v)
+	return stringToMap(string(dat)), nil
 }
 
+// stringToMap parses the cmdline into a map of keys in dot notation. Values
+// are typed, see typedValue, and keys set more than once become a list with
+// all their values in order.
 func stringToMap(s string) map[string]interface{} {
 	v := map[string]interface{}{}
+	repeated := map[string]bool{}
 
 	splitted, _ := shlex.Split(s)
 	for _, item := range splitted {
@@ -33,8 +48,37 @@ func stringToMap(s string) map[string]interface{} {
 			value = strings.Trim(parts[1], `"`)
 		}
 		key := strings.Trim(parts[0], `"`)
-		v[key] = value
+
+		previous, found := v[key]
+		switch {
+		case !found:
+			v[key] = typedValue(value)
+		case repeated[key]:
+			v[key] = append(previous.([]interface{}), typedValue(value))
+		default:
+			v[key] = []interface{}{previous, typedValue(value)}
+			repeated[key] = true
+		}
 	}
 
 	return v
 }
+
+// typedValue returns the value as a boolean or a number if it's one, or as
+// it is otherwise. Numbers are only typed if they don't change when formatted
+// back, so values like "0755" or "1.10" are kept as strings.
+func typedValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(i, 10) == s {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return s
+}
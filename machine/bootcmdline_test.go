@@ -35,5 +35,60 @@ var _ = Describe("BootCMDLine", func() {
 			_, err = DotToYAML(f.Name())
 			Expect(err).ToNot(HaveOccurred())
 		})
+		It("nests keys with dashes or symbols", func() {
+			f, err := os.CreateTemp("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(f.Name())
+
+			err = os.WriteFile(f.Name(), []byte(`install.grub-entry-name=foo install.$merge.bind_mounts=replace`), os.ModePerm)
+			Expect(err).ToNot(HaveOccurred())
+
+			b, err := DotToYAML(f.Name())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(string(b)).To(Equal("install:\n    $merge:\n        bind_mounts: replace\n    grub-entry-name: foo\n"), string(b))
+		})
+		It("types values and turns repeated keys into lists", func() {
+			f, err := os.CreateTemp("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(f.Name())
+
+			err = os.WriteFile(f.Name(), []byte(`install.bind_mounts=/a install.bind_mounts=/b install.bind_mounts=/c uki-max-entries=3 ratio=0.5 mode=0755 version=1.10 install.auto`), os.ModePerm)
+			Expect(err).ToNot(HaveOccurred())
+
+			b, err := DotToYAML(f.Name())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(string(b)).To(Equal(`install:
+    auto: true
+    bind_mounts:
+        - /a
+        - /b
+        - /c
+mode: "0755"
+ratio: 0.5
+uki-max-entries: 3
+version: "1.10"
+`), string(b))
+		})
+		It("sets list items by index", func() {
+			f, err := os.CreateTemp("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(f.Name())
+
+			err = os.WriteFile(f.Name(), []byte(`users[0].name=kairos users[0].groups[1]=admin users[1].name=guest`), os.ModePerm)
+			Expect(err).ToNot(HaveOccurred())
+
+			b, err := DotToYAML(f.Name())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(string(b)).To(Equal(`users:
+    - groups:
+        - null
+        - admin
+      name: kairos
+    - name: guest
+`), string(b))
+		})
 	})
 })
@@ -42,3 +42,19 @@ func LocalIPs() (ips []string) {
 	}
 	return
 }
+
+// MACs returns the hardware address of every network interface, by name.
+func MACs() map[string]string {
+	macs := map[string]string{}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return macs
+	}
+	for _, i := range ifaces {
+		if i.Flags&net.FlagLoopback != 0 || len(i.HardwareAddr) == 0 {
+			continue
+		}
+		macs[i.Name] = i.HardwareAddr.String()
+	}
+	return macs
+}
@@ -0,0 +1,10 @@
+package schema
+
+// BootloaderSchema represents the bootloader block in the Kairos configuration. It configures systemd-boot on UKI installs, see GrubOptionsSchema for grub.
+type BootloaderSchema struct {
+	_           struct{} `title:"Kairos Schema: Bootloader block" description:"The bootloader block configures systemd-boot on UKI installs."`
+	Timeout     int      `json:"timeout,omitempty" minimum:"0" description:"Seconds the boot menu is shown for"`
+	Default     string   `json:"default,omitempty" description:"Glob of the default boot entry" example:"active.conf"`
+	ConsoleMode string   `json:"console-mode,omitempty" enum:"[\"auto\",\"keep\",\"max\",\"0\",\"1\",\"2\"]" description:"Resolution of the boot menu"`
+	Editor      bool     `json:"editor,omitempty" description:"Allows editing the kernel cmdline from the boot menu"`
+}
@@ -0,0 +1,43 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bootloader Schema", func() {
+	var config *KConfig
+	var err error
+	var yaml string
+
+	JustBeforeEach(func() {
+		config, err = NewConfigFromYAML(yaml, BootloaderSchema{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with a timeout and console mode", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+timeout: 5
+console-mode: max
+default: active.conf`
+		})
+
+		It("succeeds", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("when the timeout is negative", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+timeout: -1`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("must be >= 0"))
+		})
+	})
+})
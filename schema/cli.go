@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	schemaVersionFlag *cli.StringFlag = &cli.StringFlag{
+		Name:  "version",
+		Value: CurrentVersion,
+		Usage: "the Kairos version of the schema (e.g. v3.3.0)",
+	}
+
+	outputFlag *cli.StringFlag = &cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Value:   "",
+		Usage:   "the file to write to, instead of the standard output",
+	}
+)
+
+func CliCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "docs",
+			Usage: "generates the Markdown reference of the Kairos configuration",
+			Flags: []cli.Flag{schemaVersionFlag, outputFlag},
+			Action: func(cCtx *cli.Context) error {
+				return generateFromFlags(cCtx, Markdown)
+			},
+		},
+		{
+			Name:  "example",
+			Usage: "generates an example #cloud-config with every key commented out and described",
+			Flags: []cli.Flag{schemaVersionFlag, outputFlag},
+			Action: func(cCtx *cli.Context) error {
+				return generateFromFlags(cCtx, Example)
+			},
+		},
+	}
+}
+
+// generateFromFlags writes what generate makes of the JSON Schema of the
+// version flag to the output flag.
+func generateFromFlags(cCtx *cli.Context, generate func(w io.Writer, jsonSchema string) error) error {
+	jsonSchema, err := VersionedJSONSchema(schemaVersionFlag.Get(cCtx))
+	if err != nil {
+		return err
+	}
+
+	output := outputFlag.Get(cCtx)
+	if output == "" {
+		return generate(os.Stdout, jsonSchema)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := generate(f, jsonSchema); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
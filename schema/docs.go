@@ -0,0 +1,355 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// docNode is a key of the configuration described by a JSON Schema, with the
+// annotations of the schema used to document it.
+type docNode struct {
+	// Path is the dot separated path of the key, with "[]" for the items of
+	// lists and "<name>" for the keys of maps, e.g. "users[].name".
+	Path        string
+	Name        string
+	Title       string
+	Description string
+	Required    bool
+	Deprecated  bool
+	// List is true if the value is a list and Children are the keys of its
+	// items.
+	List     bool
+	Schema   map[string]interface{}
+	Children []*docNode
+}
+
+// docTree returns the keys of the configuration described by the JSON Schema.
+// Its root is the top level of the configuration.
+func docTree(jsonSchema string) (*docNode, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonSchema), &root); err != nil {
+		return nil, fmt.Errorf("parsing the JSON Schema: %w", err)
+	}
+	return newDocNode(root, "", "", root, false, 0), nil
+}
+
+// newDocNode documents the key with the given property schema, which can
+// reference a definition in root.
+func newDocNode(root map[string]interface{}, name, p string, property map[string]interface{}, required bool, depth int) *docNode {
+	s := resolveRef(root, property)
+	if s == nil {
+		s = map[string]interface{}{}
+	}
+	n := &docNode{Path: p, Name: name, Required: required, Schema: s}
+	n.Title, _ = s["title"].(string)
+	n.Description, _ = property["description"].(string)
+	if n.Description == "" {
+		n.Description, _ = s["description"].(string)
+	}
+	n.Deprecated, _ = property["deprecated"].(bool)
+
+	item, itemPath := s, p
+	if items, ok := s["items"].(map[string]interface{}); ok && typeOf(s) == "array" {
+		item, itemPath = resolveRef(root, items), p+"[]"
+		n.List = true
+		if item != nil && n.Title == "" {
+			n.Title, _ = item["title"].(string)
+		}
+		if item != nil && n.Description == "" {
+			n.Description, _ = item["description"].(string)
+		}
+	}
+	// The schemas are not recursive, the depth only protects from broken ones.
+	if item == nil || depth > 10 {
+		return n
+	}
+	properties, additional, free := keysOf(root, item)
+	if len(properties) == 0 && additional != nil {
+		// The keys of maps are free, so their values are documented once.
+		if values := newDocNode(root, "<name>", itemPath+".<name>", additional, false, depth+1); len(values.Children) > 0 {
+			n.Children = []*docNode{values}
+		}
+		return n
+	}
+	if free && len(properties) == 0 {
+		return n
+	}
+
+	requiredKeys := map[string]bool{}
+	if r, ok := item["required"].([]interface{}); ok {
+		for _, k := range r {
+			if key, ok := k.(string); ok {
+				requiredKeys[key] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for key := range properties {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		childPath := key
+		if itemPath != "" {
+			childPath = itemPath + "." + key
+		}
+		n.Children = append(n.Children, newDocNode(root, key, childPath, properties[key], requiredKeys[key], depth+1))
+	}
+	return n
+}
+
+// typeOf returns the JSON type of a schema, ignoring "null", or "object" if it
+// has no type but properties.
+func typeOf(s map[string]interface{}) string {
+	switch t := s["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, tt := range t {
+			if name, ok := tt.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	if _, ok := s["properties"]; ok {
+		return "object"
+	}
+	for _, keyword := range []string{"oneOf", "anyOf", "allOf"} {
+		if _, ok := s[keyword]; ok {
+			return "object"
+		}
+	}
+	return ""
+}
+
+// typeName returns a readable type of the key, e.g. "list of string" or "map
+// of list of object".
+func (n *docNode) typeName(root map[string]interface{}) string {
+	return schemaTypeName(root, n.Schema, 0)
+}
+
+func schemaTypeName(root, s map[string]interface{}, depth int) string {
+	s = resolveRef(root, s)
+	if s == nil || depth > 10 {
+		return "any"
+	}
+	t := typeOf(s)
+	switch t {
+	case "array":
+		if items, ok := s["items"].(map[string]interface{}); ok && len(items) > 0 {
+			return "list of " + schemaTypeName(root, items, depth+1)
+		}
+		return "list"
+	case "object":
+		if additional, ok := s["additionalProperties"].(map[string]interface{}); ok {
+			if _, hasProperties := s["properties"]; !hasProperties {
+				return "map of " + schemaTypeName(root, additional, depth+1)
+			}
+		}
+		return "object"
+	case "":
+		return "any"
+	}
+	return t
+}
+
+// notes returns the description of the key followed by its constraints, e.g.
+// the allowed values and examples, as sentences. The values are formatted by
+// code.
+func (n *docNode) notes(code func(string) string) []string {
+	notes := []string{}
+	if n.Description != "" {
+		description := strings.TrimSpace(n.Description)
+		if !strings.HasSuffix(description, ".") {
+			description += "."
+		}
+		notes = append(notes, description)
+	}
+	if n.Deprecated && !strings.HasPrefix(n.Description, "Deprecated") {
+		notes = append(notes, "Deprecated.")
+	}
+	if n.Required {
+		notes = append(notes, "Required.")
+	}
+	if enum, ok := n.Schema["enum"].([]interface{}); ok {
+		notes = append(notes, "One of: "+joinValues(enum, code)+".")
+	}
+	if examples, ok := n.Schema["examples"].([]interface{}); ok {
+		label := "Example: "
+		if len(examples) > 1 {
+			label = "Examples: "
+		}
+		notes = append(notes, label+joinValues(examples, code)+".")
+	}
+	return notes
+}
+
+// sample returns a value of the key for examples: its default, its first
+// example, its first allowed value or the zero value of its type.
+func (n *docNode) sample() interface{} {
+	if d, ok := n.Schema["default"]; ok {
+		return d
+	}
+	for _, keyword := range []string{"examples", "enum"} {
+		if values, ok := n.Schema[keyword].([]interface{}); ok && len(values) > 0 {
+			// The examples of lists can be examples of their items.
+			if _, isList := values[0].([]interface{}); typeOf(n.Schema) == "array" && !isList {
+				return []interface{}{values[0]}
+			}
+			return values[0]
+		}
+	}
+	switch typeOf(n.Schema) {
+	case "string":
+		return ""
+	case "boolean":
+		return false
+	case "integer", "number":
+		return 0
+	case "array":
+		return []interface{}{}
+	case "object":
+		return map[string]interface{}{}
+	}
+	return nil
+}
+
+// inline returns the value as JSON, which is also valid YAML.
+func inline(v interface{}) string {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(dat)
+}
+
+func joinValues(values []interface{}, code func(string) string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, code(inline(v)))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Markdown writes the reference of the configuration described by the JSON
+// Schema, e.g. the one from JSONSchema, with a table of the keys of every
+// block.
+func Markdown(w io.Writer, jsonSchema string) error {
+	tree, err := docTree(jsonSchema)
+	if err != nil {
+		return err
+	}
+	root := tree.Schema
+
+	sb := &strings.Builder{}
+	title := tree.Title
+	if title == "" {
+		title = "Configuration reference"
+	}
+	fmt.Fprintf(sb, "# %s\n", title)
+	if tree.Description != "" {
+		fmt.Fprintf(sb, "\n%s\n", tree.Description)
+	}
+
+	var section func(n *docNode)
+	section = func(n *docNode) {
+		if n.Path != "" {
+			fmt.Fprintf(sb, "\n## `%s`\n", n.Path)
+			if n.Title != "" {
+				fmt.Fprintf(sb, "\n%s\n", n.Title)
+			}
+			if notes := n.notes(markdownCode); len(notes) > 0 {
+				fmt.Fprintf(sb, "\n%s\n", strings.Join(notes, " "))
+			}
+		}
+		sb.WriteString("\n| Key | Type | Default | Description |\n| --- | --- | --- | --- |\n")
+		for _, c := range n.Children {
+			def := ""
+			if d, ok := c.Schema["default"]; ok {
+				def = markdownCode(inline(d))
+			}
+			fmt.Fprintf(sb, "| `%s` | %s | %s | %s |\n", c.Path, c.typeName(root), escapeCell(def), escapeCell(strings.Join(c.notes(markdownCode), " ")))
+		}
+		for _, c := range n.Children {
+			if len(c.Children) > 0 {
+				section(c)
+			}
+		}
+	}
+	section(tree)
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// markdownCode formats a value as inline code.
+func markdownCode(s string) string {
+	return "`" + s + "`"
+}
+
+// plain leaves a value as it is.
+func plain(s string) string {
+	return s
+}
+
+// escapeCell makes the text fit in a cell of a Markdown table.
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Example writes a #cloud-config with every key of the configuration described
+// by the JSON Schema, e.g. the one from JSONSchema, set to its default or an
+// example value. The keys are commented out with "# " and described by the
+// comments above them, so removing the first "# " of the lines of a block
+// enables it.
+func Example(w io.Writer, jsonSchema string) error {
+	tree, err := docTree(jsonSchema)
+	if err != nil {
+		return err
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("#cloud-config\n")
+	if tree.Title != "" || tree.Description != "" {
+		fmt.Fprintf(sb, "## %s\n", strings.TrimPrefix(strings.Join([]string{tree.Title, tree.Description}, ": "), ": "))
+	}
+	sb.WriteString("## Remove the first \"# \" of the lines of a key to set it.\n")
+
+	var key func(n *docNode, indent string)
+	key = func(n *docNode, indent string) {
+		for _, note := range n.notes(plain) {
+			for _, line := range strings.Split(note, "\n") {
+				if indent == "" {
+					fmt.Fprintf(sb, "## %s\n", line)
+				} else {
+					fmt.Fprintf(sb, "# %s# %s\n", indent, line)
+				}
+			}
+		}
+		switch {
+		case len(n.Children) == 0:
+			fmt.Fprintf(sb, "# %s%s: %s\n", indent, n.Name, inline(n.sample()))
+		case n.List:
+			fmt.Fprintf(sb, "# %s%s:\n# %s  -\n", indent, n.Name, indent)
+			for _, c := range n.Children {
+				key(c, indent+"    ")
+			}
+		default:
+			fmt.Fprintf(sb, "# %s%s:\n", indent, n.Name)
+			for _, c := range n.Children {
+				key(c, indent+"  ")
+			}
+		}
+	}
+	for _, c := range tree.Children {
+		sb.WriteString("\n")
+		key(c, "")
+	}
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,141 @@
+package schema_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Docs", func() {
+	jsonSchema := `{
+  "title": "Test Schema",
+  "description": "Describes a test config.",
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "description": "Name of the node", "examples": ["node-1"]},
+    "role": {"type": "string", "enum": ["master", "worker"], "default": "worker"},
+    "pattern": {"type": "string", "description": "Either a|b"},
+    "old": {"type": "boolean", "description": "Old switch", "deprecated": true},
+    "disks": {"type": "array", "items": {"$ref": "#/definitions/Disk"}},
+    "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+  },
+  "definitions": {
+    "Disk": {
+      "title": "Disk block",
+      "required": ["device"],
+      "properties": {
+        "device": {"type": "string"},
+        "size": {"type": "integer", "default": 10}
+      }
+    }
+  }
+}`
+
+	Describe("Markdown", func() {
+		It("documents every key in a table of its block", func() {
+			buf := &bytes.Buffer{}
+			Expect(Markdown(buf, jsonSchema)).To(Succeed())
+			Expect(buf.String()).To(Equal("# Test Schema\n" +
+				"\n" +
+				"Describes a test config.\n" +
+				"\n" +
+				"| Key | Type | Default | Description |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| `disks` | list of object |  |  |\n" +
+				"| `labels` | map of string |  |  |\n" +
+				"| `name` | string |  | Name of the node. Required. Example: `\"node-1\"`. |\n" +
+				"| `old` | boolean |  | Old switch. Deprecated. |\n" +
+				"| `pattern` | string |  | Either a\\|b. |\n" +
+				"| `role` | string | `\"worker\"` | One of: `\"master\"`, `\"worker\"`. |\n" +
+				"\n" +
+				"## `disks`\n" +
+				"\n" +
+				"Disk block\n" +
+				"\n" +
+				"| Key | Type | Default | Description |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| `disks[].device` | string |  | Required. |\n" +
+				"| `disks[].size` | integer | `10` |  |\n"))
+		})
+
+		It("documents the Root Schema", func() {
+			jsonSchema, err := JSONSchema(CurrentVersion)
+			Expect(err).ToNot(HaveOccurred())
+			buf := &bytes.Buffer{}
+			Expect(Markdown(buf, jsonSchema)).To(Succeed())
+			Expect(buf.String()).To(HavePrefix("# Kairos Schema\n"))
+			Expect(buf.String()).To(ContainSubstring("| `install.device` | string |  | Device for automated installs. Examples: `\"auto\"`, `\"/dev/sda\"`. |"))
+			Expect(buf.String()).To(ContainSubstring("## `stages.<name>[].files`"))
+		})
+
+		It("fails on invalid JSON", func() {
+			Expect(Markdown(&bytes.Buffer{}, "{")).ToNot(Succeed())
+		})
+	})
+
+	Describe("Example", func() {
+		It("comments out and describes every key", func() {
+			buf := &bytes.Buffer{}
+			Expect(Example(buf, jsonSchema)).To(Succeed())
+			Expect(buf.String()).To(Equal(`#cloud-config
+## Test Schema: Describes a test config.
+## Remove the first "# " of the lines of a key to set it.
+
+# disks:
+#   -
+#     # Required.
+#     device: ""
+#     size: 10
+
+# labels: {}
+
+## Name of the node.
+## Required.
+## Example: "node-1".
+# name: "node-1"
+
+## Old switch.
+## Deprecated.
+# old: false
+
+## Either a|b.
+# pattern: ""
+
+## One of: "master", "worker".
+# role: "worker"
+`))
+		})
+
+		It("is a valid config of the Root Schema once uncommented", func() {
+			jsonSchema, err := JSONSchema(CurrentVersion)
+			Expect(err).ToNot(HaveOccurred())
+			buf := &bytes.Buffer{}
+			Expect(Example(buf, jsonSchema)).To(Succeed())
+
+			lines := strings.Split(buf.String(), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimPrefix(line, "# ")
+			}
+			config := strings.Join(lines, "\n")
+
+			var parsed map[string]interface{}
+			Expect(yaml.Unmarshal([]byte(config), &parsed)).To(Succeed())
+			Expect(parsed).To(HaveKey("install"))
+			Expect(parsed["users"]).To(Equal([]interface{}{map[string]interface{}{
+				"groups":              []interface{}{},
+				"lockPasswd":          true,
+				"name":                "kairos",
+				"passwd":              "kairos",
+				"ssh_authorized_keys": []interface{}{"github:USERNAME"},
+			}}))
+
+			results, err := Lint(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results.AtLeast(SeverityWarning)).To(BeEmpty())
+		})
+	})
+})
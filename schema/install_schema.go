@@ -15,7 +15,7 @@ type InstallSchema struct {
 	EphemeralMounts     []string       `json:"ephemeral_mounts,omitempty"`
 	EncryptedPartitions []string       `json:"encrypted_partitions,omitempty"`
 	Env                 []interface{}  `json:"env,omitempty"`
-	GrubOptionsSchema   `json:"grub_options,omitempty"`
+	GrubOptionsSchema   `json:"grub_options,omitempty" deprecated:"true" description:"Deprecated, use the grub_options at the top level instead"`
 	Image               string `json:"image,omitempty" description:"Use a different container image for the installation"`
 	PowerManagement
 	SkipEncryptCopyPlugins bool                `json:"skip_copy_kcrypt_plugin,omitempty"`
@@ -49,11 +49,12 @@ type ElementalPartitions struct {
 
 // BundleSchema represents the bundle block which can be used in different places of the Kairos configuration. It is used to reference a bundle and its confguration.
 type BundleSchema struct {
-	DB         string   `json:"db_path,omitempty"`
-	LocalFile  bool     `json:"local_file,omitempty"`
-	Repository string   `json:"repository,omitempty"`
-	Rootfs     string   `json:"rootfs_path,omitempty"`
-	Targets    []string `json:"targets,omitempty"`
+	DB         string             `json:"db_path,omitempty"`
+	LocalFile  bool               `json:"local_file,omitempty"`
+	Repository string             `json:"repository,omitempty"`
+	Rootfs     string             `json:"rootfs_path,omitempty"`
+	Targets    []string           `json:"targets,omitempty"`
+	Auth       RegistryAuthSchema `json:"auth,omitempty" description:"Credentials for the registry the bundles are pulled from"`
 }
 
 // GrubOptionsSchema represents the grub options block which can be used in different places of the Kairos configuration. It is used to configure grub.
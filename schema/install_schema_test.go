@@ -40,6 +40,38 @@ device: /dev/sda`
 		})
 	})
 
+	Context("with bundles from a private registry", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+device: auto
+bundles:
+- targets: [run://quay.io/org/bundle:latest]
+  auth:
+    username: user
+    password: pass`
+		})
+
+		It("succeedes", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("with registry credentials of the wrong type", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+device: auto
+bundles:
+- targets: [run://quay.io/org/bundle:latest]
+  auth:
+    password: 1234`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("expected string, but got number"))
+		})
+	})
+
 	Context("when device is other than a path or auto", func() {
 		BeforeEach(func() {
 			yaml = `#cloud-config
@@ -0,0 +1,26 @@
+// Command generate writes the JSON Schema of schema.RootSchema to the registry
+// as the one of schema.CurrentVersion. It's run by go generate from the schema
+// directory.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kairos-io/kairos-sdk/schema"
+)
+
+func main() {
+	generated, err := schema.GenerateSchema(schema.RootSchema{}, schema.SchemaURL(schema.CurrentVersion))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	file := filepath.Join("versions", schema.CurrentVersion+".json")
+	if err := os.WriteFile(file, []byte(generated+"\n"), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
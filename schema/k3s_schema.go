@@ -0,0 +1,12 @@
+package schema
+
+// K3sSchema represents the k3s and k3s-agent blocks in the Kairos configuration. They configure the k3s server or agent started on boot.
+type K3sSchema struct {
+	_                struct{}          `title:"Kairos Schema: k3s block" description:"The k3s and k3s-agent blocks configure the k3s server or agent started on boot."`
+	Enabled          bool              `json:"enabled,omitempty" description:"Enables the k3s service"`
+	Args             []string          `json:"args,omitempty" description:"Additional arguments for k3s" examples:"[[\"--disable=traefik\"]]"`
+	Env              map[string]string `json:"env,omitempty" description:"Environment variables for k3s"`
+	ReplaceArgs      bool              `json:"replace_args,omitempty" description:"Replace the default arguments of k3s with args instead of appending them"`
+	ReplaceEnv       bool              `json:"replace_env,omitempty" description:"Replace the default environment of k3s with env instead of adding it"`
+	EmbeddedRegistry bool              `json:"embedded_registry,omitempty" description:"Enables the embedded registry mirror of k3s"`
+}
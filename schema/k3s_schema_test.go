@@ -0,0 +1,47 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("K3s Schema", func() {
+	var config *KConfig
+	var err error
+	var yaml string
+
+	JustBeforeEach(func() {
+		config, err = NewConfigFromYAML(yaml, K3sSchema{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with args and env", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+enabled: true
+args:
+- --disable=traefik
+env:
+  K3S_TOKEN: foo
+replace_args: true`
+		})
+
+		It("succeeds", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("when args is not a list", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+enabled: true
+args: --disable=traefik`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("expected array, but got string"))
+		})
+	})
+})
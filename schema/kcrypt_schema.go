@@ -0,0 +1,17 @@
+package schema
+
+// KcryptSchema represents the kcrypt block in the Kairos configuration. It configures how the encrypted partitions are unlocked.
+type KcryptSchema struct {
+	_          struct{}               `title:"Kairos Schema: kcrypt block" description:"The kcrypt block configures how the encrypted partitions are unlocked."`
+	Challenger KcryptChallengerSchema `json:"challenger,omitempty"`
+}
+
+// KcryptChallengerSchema represents the challenger block of kcrypt. It sets the server that hands out the passphrases of the encrypted partitions, which are stored locally in the TPM otherwise.
+type KcryptChallengerSchema struct {
+	Server      string `json:"challenger_server,omitempty" pattern:"^(https?://.+)?$" description:"URL of the kcrypt challenger server" example:"http://192.168.1.10:8082"`
+	MDNS        bool   `json:"mdns,omitempty" description:"Resolve the challenger server with mDNS"`
+	Certificate string `json:"certificate,omitempty" description:"CA certificate of the challenger server, in PEM format"`
+	NVIndex     string `json:"nv_index,omitempty" pattern:"^(0x[0-9a-fA-F]+)?$" description:"TPM NV index to store the passphrase in, when there is no challenger server"`
+	CIndex      string `json:"c_index,omitempty" pattern:"^(0x[0-9a-fA-F]+)?$" description:"TPM index of the certificate used to encrypt the passphrase"`
+	TPMDevice   string `json:"tpm_device,omitempty" description:"Path of the TPM device" example:"/dev/tpmrm0"`
+}
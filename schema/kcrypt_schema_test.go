@@ -0,0 +1,57 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Kcrypt Schema", func() {
+	var config *KConfig
+	var err error
+	var yaml string
+
+	JustBeforeEach(func() {
+		config, err = NewConfigFromYAML(yaml, KcryptSchema{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with a challenger server", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+challenger:
+  challenger_server: http://192.168.1.10:8082
+  nv_index: "0x1500000"
+  mdns: true`
+		})
+
+		It("succeeds", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("when the challenger server is not a URL", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+challenger:
+  challenger_server: 192.168.1.10:8082`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("does not match pattern"))
+		})
+	})
+
+	Context("when the nv index is not hexadecimal", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+challenger:
+  nv_index: 1500000`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,339 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity of a result of Lint.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+var severityLevels = map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityError: 2}
+
+func (s Severity) validate() error {
+	if _, ok := severityLevels[s]; !ok {
+		return fmt.Errorf("unknown severity %q, must be one of %q, %q or %q", s, SeverityError, SeverityWarning, SeverityInfo)
+	}
+	return nil
+}
+
+// AtLeast returns the results with the given severity or a higher one, e.g.
+// the errors and warnings for SeverityWarning. Results without a severity are
+// errors.
+func (r ValidationResults) AtLeast(s Severity) ValidationResults {
+	result := ValidationResults{}
+	for _, v := range r {
+		severity := v.Severity
+		if severity == "" {
+			severity = SeverityError
+		}
+		if severityLevels[severity] >= severityLevels[s] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// DefaultLintAllowedKeys are the keys understood by the collector at any
+// depth of a config: the "$merge" directives and the "encrypted" sections.
+var DefaultLintAllowedKeys = []string{"$merge", "encrypted"}
+
+// LintOptions configure Lint.
+type LintOptions struct {
+	// Allowlist are the paths of the keys that are never flagged, with their
+	// children, e.g. "install.custom" or "users.*.x-*".
+	Allowlist []string
+	// AllowedKeys are the names of the keys that are never flagged, with
+	// their children, wherever they are. Defaults to DefaultLintAllowedKeys.
+	AllowedKeys []string
+	// Typos is the severity of the unknown keys that look like a typo of a
+	// known one, e.g. "instal".
+	Typos Severity
+	// UnknownKeys is the severity of the rest of unknown keys.
+	UnknownKeys Severity
+	// Deprecated is the severity of the keys deprecated in the schema.
+	Deprecated Severity
+}
+
+// LintOption sets LintOptions.
+type LintOption func(o *LintOptions) error
+
+// Apply applies the options, in order.
+func (o *LintOptions) Apply(opts ...LintOption) error {
+	for _, oo := range opts {
+		if err := oo(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LintAllowlist adds paths to LintOptions.Allowlist. Their segments are globs,
+// so "*" matches any key or index.
+func LintAllowlist(paths ...string) LintOption {
+	return func(o *LintOptions) error {
+		for _, p := range paths {
+			if _, err := path.Match(dotToSlash(p), ""); err != nil {
+				return fmt.Errorf("invalid allowlist path %q: %w", p, err)
+			}
+		}
+		o.Allowlist = append(o.Allowlist, paths...)
+		return nil
+	}
+}
+
+// LintSeverities sets the severities of typos, other unknown keys and
+// deprecated keys.
+func LintSeverities(typos, unknownKeys, deprecated Severity) LintOption {
+	return func(o *LintOptions) error {
+		for _, s := range []Severity{typos, unknownKeys, deprecated} {
+			if err := s.validate(); err != nil {
+				return err
+			}
+		}
+		o.Typos, o.UnknownKeys, o.Deprecated = typos, unknownKeys, deprecated
+		return nil
+	}
+}
+
+// Lint validates the config in the source, like Validate, and flags the keys
+// that are not in the Root Schema, see KConfig.Lint.
+func Lint(source string, opts ...LintOption) (ValidationResults, error) {
+	yaml, err := readSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := NewConfigFromYAML(yaml, RootSchema{})
+	if err != nil {
+		return nil, err
+	}
+	if !config.HasHeader() {
+		return nil, fmt.Errorf("missing #cloud-config header")
+	}
+
+	return config.Lint(opts...)
+}
+
+// Lint returns the violations of the schema, as errors, and the keys of the
+// config that are not in the schema or are deprecated, ordered by line. As the
+// schema allows additional keys, these are valid but usually mistakes. The
+// deprecated #node-config header is flagged like deprecated keys.
+//
+// Keys of maps, like the names of the stages, and of values without a schema,
+// like the ones in cluster.providerConfig, are never flagged, as they are
+// extension points. Unknown keys of schemas that don't allow additional keys
+// are already violations of the schema, so they are not flagged twice.
+func (kc *KConfig) Lint(opts ...LintOption) (ValidationResults, error) {
+	o := &LintOptions{
+		AllowedKeys: append([]string{}, DefaultLintAllowedKeys...),
+		Typos:       SeverityError,
+		UnknownKeys: SeverityWarning,
+		Deprecated:  SeverityInfo,
+	}
+	if err := o.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	results, err := kc.Results()
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Severity = SeverityError
+	}
+
+	generatedSchemaJSON, err := kc.jsonSchema()
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(generatedSchemaJSON), &root); err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(kc.Source), &doc); err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(kc.Source, "#node-config") {
+		results = append(ValidationResults{{
+			Line:       1,
+			Column:     1,
+			Message:    `deprecated header "#node-config"`,
+			Suggestion: `use "#cloud-config" instead, see Migrate`,
+			Severity:   o.Deprecated,
+		}}, results...)
+	}
+	if len(doc.Content) > 0 {
+		l := &linter{options: o, root: root}
+		l.walk(root, doc.Content[0], nil)
+		results = append(results, l.results...)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Line < results[j].Line
+	})
+	return results, nil
+}
+
+type linter struct {
+	options *LintOptions
+	root    map[string]interface{}
+	results ValidationResults
+}
+
+// walk flags the unknown and deprecated keys of the node, which is validated
+// by the schema s, and its children.
+func (l *linter) walk(s map[string]interface{}, node *yaml.Node, p []string) {
+	s = resolveRef(l.root, s)
+	if s == nil || node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		properties, additional, free := keysOf(l.root, s)
+		if free {
+			return
+		}
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := append(append([]string{}, p...), key.Value)
+			if l.allowed(child) {
+				continue
+			}
+
+			if property, ok := properties[key.Value]; ok {
+				if deprecated, _ := property["deprecated"].(bool); deprecated {
+					description, _ := property["description"].(string)
+					l.results = append(l.results, ValidationResult{
+						Path:       strings.Join(child, "."),
+						Line:       key.Line,
+						Column:     key.Column,
+						Message:    fmt.Sprintf("deprecated key %q", key.Value),
+						Suggestion: description,
+						Severity:   l.options.Deprecated,
+					})
+				}
+				l.walk(property, value, child)
+				continue
+			}
+			if additional != nil {
+				l.walk(additional, value, child)
+				continue
+			}
+
+			r := unknownKey(p, key, names)
+			r.Severity = l.options.UnknownKeys
+			if r.Suggestion != "" {
+				r.Severity = l.options.Typos
+			}
+			l.results = append(l.results, r)
+		}
+	case yaml.SequenceNode:
+		items, _ := s["items"].(map[string]interface{})
+		for i, item := range node.Content {
+			l.walk(items, item, append(append([]string{}, p...), strconv.Itoa(i)))
+		}
+	}
+}
+
+// keysOf returns the properties of an object schema, including the ones of
+// its alternatives, and the schema of the rest of keys if it's a map. free is
+// true if any key is allowed, because the schema doesn't define any or it
+// already forbids the unknown ones. References are resolved in root.
+func keysOf(root, s map[string]interface{}) (properties map[string]map[string]interface{}, additional map[string]interface{}, free bool) {
+	properties = map[string]map[string]interface{}{}
+	forbidden := false
+
+	var collect func(s map[string]interface{})
+	collect = func(s map[string]interface{}) {
+		s = resolveRef(root, s)
+		if s == nil {
+			return
+		}
+		if ps, ok := s["properties"].(map[string]interface{}); ok {
+			for name, p := range ps {
+				if property, ok := p.(map[string]interface{}); ok {
+					if _, found := properties[name]; !found {
+						properties[name] = property
+					}
+				}
+			}
+		}
+		switch a := s["additionalProperties"].(type) {
+		case map[string]interface{}:
+			additional = a
+		case bool:
+			forbidden = forbidden || !a
+		}
+		for _, keyword := range []string{"oneOf", "anyOf", "allOf"} {
+			alternatives, _ := s[keyword].([]interface{})
+			for _, alternative := range alternatives {
+				if a, ok := alternative.(map[string]interface{}); ok {
+					collect(a)
+				}
+			}
+		}
+	}
+	collect(s)
+
+	free = forbidden || (len(properties) == 0 && additional == nil)
+	return properties, additional, free
+}
+
+// resolveRef returns the definition in root a schema references, if it does.
+func resolveRef(root, s map[string]interface{}) map[string]interface{} {
+	for i := 0; s != nil && i < 10; i++ {
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return s
+		}
+		definitions, _ := root["definitions"].(map[string]interface{})
+		s, _ = definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+	}
+	return s
+}
+
+// allowed returns true if the path, or one of its parents, is in the
+// allowlist or the key is one of the allowed keys.
+func (l *linter) allowed(p []string) bool {
+	for _, k := range l.options.AllowedKeys {
+		if len(p) > 0 && p[len(p)-1] == k {
+			return true
+		}
+	}
+	for i := range p {
+		key := strings.Join(p[:i+1], "/")
+		for _, a := range l.options.Allowlist {
+			if ok, _ := path.Match(dotToSlash(a), key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dotToSlash turns a dot separated path into a slash separated one, so the
+// globs of path.Match don't match across segments.
+func dotToSlash(p string) string {
+	return strings.ReplaceAll(p, ".", "/")
+}
@@ -0,0 +1,126 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lint", func() {
+	config := `#cloud-config
+users:
+- name: kairos
+  ssh_authorized_key: [github:mudler]
+instal:
+  device: /dev/sda
+install:
+  grub_options:
+    extra_cmdline: console=ttyS0
+  reboot: true
+mycompany:
+  team: platform
+cluster:
+  providerConfig:
+    custom: value
+stages:
+  my-stage:
+  - commands: [echo hello]
+    comands: [echo typo]
+p2p:
+  network_token: token
+  auto:
+    enable: true
+`
+
+	It("flags unknown and deprecated keys", func() {
+		results, err := Lint(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(Equal(ValidationResults{
+			{Path: "users.0.ssh_authorized_key", Line: 4, Column: 3, Message: `unknown key "ssh_authorized_key"`, Suggestion: `did you mean "ssh_authorized_keys"?`, Severity: SeverityError},
+			{Path: "instal", Line: 5, Column: 1, Message: `unknown key "instal"`, Suggestion: `did you mean "install"?`, Severity: SeverityError},
+			{Path: "install.grub_options", Line: 8, Column: 3, Message: `deprecated key "grub_options"`, Suggestion: "Deprecated, use the grub_options at the top level instead", Severity: SeverityInfo},
+			{Path: "mycompany", Line: 11, Column: 1, Message: `unknown key "mycompany"`, Severity: SeverityWarning},
+			{Path: "stages.my-stage.0.comands", Line: 19, Column: 5, Message: `unknown key "comands"`, Suggestion: `did you mean "commands"?`, Severity: SeverityError},
+		}))
+		Expect(results.AtLeast(SeverityWarning)).To(HaveLen(4))
+		Expect(results.AtLeast(SeverityError)).To(HaveLen(3))
+	})
+
+	It("flags the deprecated #node-config header", func() {
+		results, err := Lint("#node-config\nusers:\n- name: kairos\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(Equal(ValidationResults{
+			{Line: 1, Column: 1, Message: `deprecated header "#node-config"`, Suggestion: `use "#cloud-config" instead, see Migrate`, Severity: SeverityInfo},
+		}))
+
+		results, err = Lint("#cloud-config\nusers:\n- name: kairos\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(BeEmpty())
+	})
+
+	It("includes the violations of the schema", func() {
+		results, err := Lint("#cloud-config\nusers:\n- name: 007\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(Equal(ValidationResults{
+			{Path: "users.0.name", Line: 3, Column: 3, Message: "expected string, but got number", Severity: SeverityError},
+		}))
+	})
+
+	It("skips the allowed keys", func() {
+		results, err := Lint(config, LintAllowlist("mycompany", "users.*.ssh_*", "stages.*.*.comands"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Path).To(Equal("instal"))
+		Expect(results[1].Path).To(Equal("install.grub_options"))
+	})
+
+	It("skips the merge directives and encrypted sections of the collector", func() {
+		results, err := Lint(`#cloud-config
+$merge:
+  users: append
+install:
+  $merge:
+    bind_mounts: replace
+  bind_mounts: [/var/lib/foo]
+p2p:
+  encrypted: |
+    -----BEGIN AGE ENCRYPTED FILE-----
+    -----END AGE ENCRYPTED FILE-----
+encrypted: |
+  -----BEGIN PGP MESSAGE-----
+  -----END PGP MESSAGE-----
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).ToNot(ContainElement(HaveField("Message", HavePrefix("unknown key"))))
+
+		results, err = Lint("#cloud-config\nusers: [{name: kairos}]\n$merge:\n  users: append\n", func(o *LintOptions) error {
+			o.AllowedKeys = nil
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(ConsistOf(HaveField("Path", "$merge")))
+	})
+
+	It("uses the given severities", func() {
+		results, err := Lint(config, LintSeverities(SeverityWarning, SeverityInfo, SeverityWarning))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results.AtLeast(SeverityError)).To(BeEmpty())
+		Expect(results.AtLeast(SeverityWarning)).To(HaveLen(4))
+	})
+
+	It("validates the options", func() {
+		_, err := Lint(config, LintAllowlist("["))
+		Expect(err).To(HaveOccurred())
+		_, err = Lint(config, LintSeverities("fatal", SeverityInfo, SeverityInfo))
+		Expect(err).To(MatchError(ContainSubstring(`unknown severity "fatal"`)))
+	})
+
+	It("lints against a version of the schema", func() {
+		kc, err := NewVersionedConfigFromYAML("#cloud-config\nusers:\n- name: kairos\nk3s:\n  enabled: true\n", "v3.2.0")
+		Expect(err).ToNot(HaveOccurred())
+		results, err := kc.Lint()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Path).To(Equal("k3s"))
+	})
+})
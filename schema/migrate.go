@@ -0,0 +1,270 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a config being migrated, see Migration.
+type Document struct {
+	// Header is the first line of the config, e.g. "#cloud-config", or empty
+	// if it has none.
+	Header string
+	// Root is the mapping at the top of the config.
+	Root *yaml.Node
+}
+
+// Migration rewrites a deprecated form of the config to the current one.
+type Migration struct {
+	// Version is the release that deprecated the old form. Configs written for
+	// older versions are migrated.
+	Version     string
+	Description string
+	// Migrate rewrites the document and returns the changes it made, if any.
+	Migrate func(doc *Document) []Change
+}
+
+// Change is a rewrite made by a Migration.
+type Change struct {
+	// Version is the version of the Migration.
+	Version string `json:"version"`
+	// Path is the dot separated path of the deprecated key, or empty if the
+	// header was rewritten.
+	Path string `json:"path,omitempty"`
+	// NewPath is where the value of Path was moved to, if it was.
+	NewPath string `json:"new_path,omitempty"`
+	// Line of Path in the original config, 0 if unknown.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (c Change) String() string {
+	sb := &strings.Builder{}
+	if c.Line > 0 {
+		fmt.Fprintf(sb, "%d: ", c.Line)
+	}
+	if c.Path != "" {
+		fmt.Fprintf(sb, "%s: ", c.Path)
+	}
+	fmt.Fprintf(sb, "%s (deprecated in %s)", c.Message, c.Version)
+	return sb.String()
+}
+
+// MigrationReport lists the changes made by Migrate.
+type MigrationReport struct {
+	// From is the version the config was written for, empty if unknown.
+	From    string   `json:"from,omitempty"`
+	To      string   `json:"to"`
+	Changes []Change `json:"changes"`
+}
+
+func (r *MigrationReport) String() string {
+	from := r.From
+	if from == "" {
+		from = "any version"
+	}
+	if len(r.Changes) == 0 {
+		return fmt.Sprintf("nothing to migrate from %s to %s\n", from, r.To)
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "migrated from %s to %s:\n", from, r.To)
+	for _, c := range r.Changes {
+		fmt.Fprintf(sb, "  %s\n", c)
+	}
+	return sb.String()
+}
+
+// Migrations are the migrations applied by Migrate, in order.
+var Migrations = []Migration{
+	{
+		Version:     "v2.0.0",
+		Description: "The p2p settings were moved from the kairos block to the p2p block.",
+		Migrate: func(doc *Document) []Change {
+			return moveKey(doc.Root, []string{"kairos"}, []string{"p2p"})
+		},
+	},
+	{
+		Version:     "v3.3.0",
+		Description: "The #node-config header is replaced by #cloud-config.",
+		Migrate: func(doc *Document) []Change {
+			if doc.Header != "#node-config" {
+				return nil
+			}
+			doc.Header = "#cloud-config"
+			return []Change{{Line: 1, Message: "replaced the #node-config header with #cloud-config"}}
+		},
+	},
+	{
+		Version:     "v3.3.0",
+		Description: "The grub options were moved from the install block to the top level.",
+		Migrate: func(doc *Document) []Change {
+			return moveKey(doc.Root, []string{"install", "grub_options"}, []string{"grub_options"})
+		},
+	},
+}
+
+// Migrate rewrites the deprecated forms of a config written for the given
+// version, or for any version if it's empty, to the ones of the
+// CurrentVersion. The config is returned as it was if nothing was migrated.
+func Migrate(source, from string) (string, *MigrationReport, error) {
+	if from != "" && !strings.HasPrefix(from, "v") {
+		from = "v" + from
+	}
+	if from != "" && !semver.IsValid(from) {
+		return "", nil, fmt.Errorf("invalid version %q", from)
+	}
+	report := &MigrationReport{From: from, To: CurrentVersion, Changes: []Change{}}
+
+	doc, err := parseDocument(source)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, m := range Migrations {
+		if from != "" && semver.Compare(from, m.Version) >= 0 {
+			continue
+		}
+		for _, c := range m.Migrate(doc) {
+			c.Version = m.Version
+			report.Changes = append(report.Changes, c)
+		}
+	}
+	if len(report.Changes) == 0 {
+		return source, report, nil
+	}
+
+	migrated, err := doc.String()
+	if err != nil {
+		return "", nil, err
+	}
+	return migrated, report, nil
+}
+
+// parseDocument splits the header from the config. It's replaced with an empty
+// line so the lines of the nodes are the ones of the source.
+func parseDocument(source string) (*Document, error) {
+	doc := &Document{}
+	first, rest, _ := strings.Cut(source, "\n")
+	for _, header := range []string{"#cloud-config", "#kairos-config", "#node-config"} {
+		if strings.TrimSpace(first) == header {
+			doc.Header = header
+			source = "\n" + rest
+		}
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(source), &node); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(node.Content) == 0:
+		doc.Root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	case node.Content[0].Kind == yaml.MappingNode:
+		doc.Root = node.Content[0]
+	default:
+		return nil, fmt.Errorf("the config must be a map")
+	}
+	return doc, nil
+}
+
+// String returns the document as YAML, with its header.
+func (doc *Document) String() (string, error) {
+	buf := &bytes.Buffer{}
+	if doc.Header != "" {
+		fmt.Fprintln(buf, doc.Header)
+	}
+	if len(doc.Root.Content) == 0 {
+		return buf.String(), nil
+	}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc.Root); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// moveKey moves the value of a key to another path. If both are mappings, the
+// keys already set in the destination are kept.
+func moveKey(root *yaml.Node, from, to []string) []Change {
+	parent := mappingAt(root, from[:len(from)-1], false)
+	if parent == nil || valueOf(parent, from[len(from)-1]) == nil {
+		return nil
+	}
+	destination := mappingAt(root, to[:len(to)-1], true)
+	if destination == nil {
+		return nil
+	}
+	key, value := removeKey(parent, from[len(from)-1])
+	change := Change{
+		Path:    strings.Join(from, "."),
+		NewPath: strings.Join(to, "."),
+		Line:    key.Line,
+		Message: fmt.Sprintf("moved to %s", strings.Join(to, ".")),
+	}
+
+	name := to[len(to)-1]
+	existing := valueOf(destination, name)
+	switch {
+	case existing == nil:
+		key.Value = name
+		destination.Content = append(destination.Content, key, value)
+	case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			if valueOf(existing, value.Content[i].Value) == nil {
+				existing.Content = append(existing.Content, value.Content[i], value.Content[i+1])
+			}
+		}
+		change.Message = fmt.Sprintf("merged into %s, keeping the keys already set there", strings.Join(to, "."))
+	default:
+		change.NewPath = ""
+		change.Message = fmt.Sprintf("removed, %s is already set", strings.Join(to, "."))
+	}
+	return []Change{change}
+}
+
+// mappingAt returns the mapping at the path, creating the missing ones if
+// create is true, or nil if there is none.
+func mappingAt(root *yaml.Node, path []string, create bool) *yaml.Node {
+	node := root
+	for _, segment := range path {
+		next := valueOf(node, segment)
+		if next == nil && create {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: segment}, next)
+		}
+		if next == nil || next.Kind != yaml.MappingNode {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// valueOf returns the value of the key in the mapping, or nil if it's not set.
+func valueOf(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKey removes the key from the mapping and returns its nodes.
+func removeKey(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			k, v := mapping.Content[i], mapping.Content[i+1]
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return k, v
+		}
+	}
+	return nil, nil
+}
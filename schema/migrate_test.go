@@ -0,0 +1,72 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	legacy := `#node-config
+# the legacy p2p block
+kairos:
+  network_token: token
+  role: master
+p2p:
+  role: worker
+install:
+  device: /dev/sda
+  grub_options:
+    extra_cmdline: console=ttyS0
+`
+
+	It("rewrites the deprecated keys", func() {
+		migrated, report, err := Migrate(legacy, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(migrated).To(Equal(`#cloud-config
+p2p:
+  role: worker
+  network_token: token
+install:
+  device: /dev/sda
+grub_options:
+  extra_cmdline: console=ttyS0
+`))
+		Expect(report.To).To(Equal(CurrentVersion))
+		Expect(report.Changes).To(Equal([]Change{
+			{Version: "v2.0.0", Path: "kairos", NewPath: "p2p", Line: 3, Message: "merged into p2p, keeping the keys already set there"},
+			{Version: "v3.3.0", Line: 1, Message: "replaced the #node-config header with #cloud-config"},
+			{Version: "v3.3.0", Path: "install.grub_options", NewPath: "grub_options", Line: 10, Message: "moved to grub_options"},
+		}))
+		Expect(report.String()).To(Equal(`migrated from any version to ` + CurrentVersion + `:
+  3: kairos: merged into p2p, keeping the keys already set there (deprecated in v2.0.0)
+  1: replaced the #node-config header with #cloud-config (deprecated in v3.3.0)
+  10: install.grub_options: moved to grub_options (deprecated in v3.3.0)
+`))
+	})
+
+	It("only applies the migrations of newer versions", func() {
+		_, report, err := Migrate(legacy, "2.1.0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.From).To(Equal("v2.1.0"))
+		Expect(report.Changes).To(HaveLen(2))
+
+		migrated, report, err := Migrate(legacy, CurrentVersion)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(migrated).To(Equal(legacy))
+		Expect(report.String()).To(Equal("nothing to migrate from " + CurrentVersion + " to " + CurrentVersion + "\n"))
+	})
+
+	It("renames blocks that are not set yet", func() {
+		migrated, _, err := Migrate("#cloud-config\nkairos:\n  role: master\n", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(migrated).To(Equal("#cloud-config\np2p:\n  role: master\n"))
+	})
+
+	It("errors on invalid input", func() {
+		_, _, err := Migrate(legacy, "latest")
+		Expect(err).To(MatchError(`invalid version "vlatest"`))
+		_, _, err = Migrate("#cloud-config\n- foo\n", "")
+		Expect(err).To(HaveOccurred())
+	})
+})
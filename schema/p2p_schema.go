@@ -30,7 +30,7 @@ type P2PNetworkExtended struct {
 
 // P2PAutoDisabled is used to validate that when p2p.auto is disabled, then neither p2p.auto.ha not p2p.network_token can be set.
 type P2PAutoDisabled struct {
-	NetworkToken string `json:"network_token,omitempty" const:"" required:"true"`
+	NetworkToken string `json:"network_token,omitempty" const:"" required:"true" writeOnly:"true"`
 	Auto         struct {
 		Enable bool `json:"enable" const:"false" required:"true"`
 		Ha     struct {
@@ -41,7 +41,7 @@ type P2PAutoDisabled struct {
 
 // P2PAutoEnabled is used to validate that when p2p.auto is set, p2p.network_token has to be set.
 type P2PAutoEnabled struct {
-	NetworkToken string `json:"network_token" required:"true" minLength:"1" description:"network_token is the shared secret used by the nodes to co-ordinate with p2p"`
+	NetworkToken string `json:"network_token" required:"true" minLength:"1" writeOnly:"true" description:"network_token is the shared secret used by the nodes to co-ordinate with p2p"`
 	Auto         struct {
 		Enable bool `json:"enable,omitempty" const:"true"`
 		Ha     struct {
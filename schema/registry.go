@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run ./internal/generate
+
+// CurrentVersion is the Kairos release described by RootSchema. Its JSON
+// Schema is kept in the registry with the ones of the previous releases, run
+// go generate after changing RootSchema to update it.
+const CurrentVersion = "v3.3.0"
+
+//go:embed versions/*.json
+var versionsFS embed.FS
+
+// Versions returns the versions in the registry of JSON Schemas, oldest first.
+func Versions() []string {
+	entries, _ := versionsFS.ReadDir("versions")
+	versions := []string{}
+	for _, e := range entries {
+		versions = append(versions, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// registeredVersion returns the version in the registry, which can be given
+// with or without the "v" prefix.
+func registeredVersion(version string) (string, bool) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	for _, v := range Versions() {
+		if v == version {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// VersionedJSONSchema returns the JSON Schema of the given version from the
+// registry.
+func VersionedJSONSchema(version string) (string, error) {
+	v, ok := registeredVersion(version)
+	if !ok {
+		return "", fmt.Errorf("unknown schema version %q, must be one of %s", version, strings.Join(Versions(), ", "))
+	}
+	dat, err := versionsFS.ReadFile(path.Join("versions", v+".json"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(dat), "\n"), nil
+}
+
+// NewVersionedConfigFromYAML is like NewConfigFromYAML but the configuration is
+// validated against the JSON Schema of the given version from the registry.
+func NewVersionedConfigFromYAML(s, version string) (*KConfig, error) {
+	versioned, err := VersionedJSONSchema(version)
+	if err != nil {
+		return nil, err
+	}
+
+	// The $schema of the registry is the URL where the schema is published, not
+	// a meta-schema the validator knows about.
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(versioned), &root); err != nil {
+		return nil, err
+	}
+	delete(root, "$schema")
+	dat, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+
+	kc := &KConfig{
+		Source:     s,
+		schemaJSON: string(dat),
+	}
+
+	err = yaml.Unmarshal([]byte(s), &kc.parsed)
+	if err != nil {
+		return kc, err
+	}
+	return kc, nil
+}
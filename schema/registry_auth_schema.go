@@ -0,0 +1,11 @@
+package schema
+
+// RegistryAuthSchema represents the credentials used to pull images from a registry, with the fields of the auths of a docker config.json. The secrets are writeOnly, so they are redacted.
+type RegistryAuthSchema struct {
+	Username      string `json:"username,omitempty" description:"User name for the registry"`
+	Password      string `json:"password,omitempty" writeOnly:"true" description:"Password for the registry"`
+	Auth          string `json:"auth,omitempty" writeOnly:"true" description:"Base64 encoded username:password"`
+	ServerAddress string `json:"serveraddress,omitempty" description:"Address of the registry the credentials are for"`
+	IdentityToken string `json:"identitytoken,omitempty" writeOnly:"true" description:"Token used to get an access token for the registry"`
+	RegistryToken string `json:"registrytoken,omitempty" writeOnly:"true" description:"Bearer token sent to the registry"`
+}
@@ -0,0 +1,51 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	It("lists the versions oldest first", func() {
+		versions := Versions()
+		Expect(versions).To(ContainElements("v3.2.0", CurrentVersion))
+		Expect(versions[len(versions)-1]).To(Equal(CurrentVersion))
+	})
+
+	It("has the schema of the current version up to date", func() {
+		generated, err := GenerateSchema(RootSchema{}, SchemaURL(CurrentVersion))
+		Expect(err).ToNot(HaveOccurred())
+		registered, err := VersionedJSONSchema(CurrentVersion)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registered).To(Equal(generated), "run go generate ./schema to update it")
+
+		fromJSONSchema, err := JSONSchema(CurrentVersion)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fromJSONSchema).To(Equal(registered))
+	})
+
+	It("returns an error for unknown versions", func() {
+		_, err := VersionedJSONSchema("v0.0.1")
+		Expect(err).To(MatchError(ContainSubstring(`unknown schema version "v0.0.1"`)))
+		_, err = NewVersionedConfigFromYAML("#cloud-config\n", "v0.0.1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("validates against the schema of a version", func() {
+		yaml := `#cloud-config
+users:
+- name: kairos
+k3s:
+  args: --disable=traefik
+`
+		// k3s was not in the schema of v3.2.0.
+		Expect(ValidateVersion(yaml, "3.2.0")).To(Succeed())
+		err := ValidateVersion(yaml, CurrentVersion)
+		Expect(err).To(MatchError(ContainSubstring("k3s.args: expected array, but got string")))
+
+		config, err := NewVersionedConfigFromYAML("#cloud-config\nusers: []\n", "v3.2.0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.IsValid()).To(BeFalse())
+	})
+})
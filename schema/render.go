@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RenderFormat is a format ValidationResults can be rendered in.
+type RenderFormat string
+
+const (
+	// RenderPretty renders the results for terminals, with the lines of the
+	// source they were found in.
+	RenderPretty RenderFormat = "pretty"
+	// RenderJSON renders the results as a JSON list, for web UIs and tools.
+	RenderJSON RenderFormat = "json"
+)
+
+// ParseRenderFormat returns the RenderFormat with the given name.
+func ParseRenderFormat(s string) (RenderFormat, error) {
+	switch f := RenderFormat(s); f {
+	case RenderPretty, RenderJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, must be one of %q or %q", s, RenderPretty, RenderJSON)
+}
+
+// Render writes the results in the given format. The source is the YAML the
+// results were found in, RenderPretty shows the offending lines of it unless
+// it's empty.
+func (r ValidationResults) Render(w io.Writer, format RenderFormat, source string) error {
+	switch format {
+	case RenderJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if r == nil {
+			r = ValidationResults{}
+		}
+		return enc.Encode(r)
+	case RenderPretty:
+		_, err := io.WriteString(w, r.pretty(source))
+		return err
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// pretty renders every result as:
+//
+//	error: users.0.name: expected string, but got number
+//	  --> line 3, column 5
+//	   3 |   - name: 007
+//	     |     ^
+//	  hint: did you mean "x"?
+func (r ValidationResults) pretty(source string) string {
+	lines := strings.Split(source, "\n")
+	sb := &strings.Builder{}
+	for i, result := range r {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		severity := result.Severity
+		if severity == "" {
+			severity = SeverityError
+		}
+		fmt.Fprintf(sb, "%s: ", severity)
+		if result.Path != "" {
+			fmt.Fprintf(sb, "%s: ", result.Path)
+		}
+		fmt.Fprintf(sb, "%s\n", result.Message)
+
+		if result.Line > 0 {
+			fmt.Fprintf(sb, "  --> line %d, column %d\n", result.Line, result.Column)
+			if source != "" && result.Line <= len(lines) {
+				number := fmt.Sprint(result.Line)
+				gutter := strings.Repeat(" ", len(number))
+				fmt.Fprintf(sb, "   %s | %s\n", number, lines[result.Line-1])
+				fmt.Fprintf(sb, "   %s | %s^\n", gutter, strings.Repeat(" ", max(result.Column-1, 0)))
+			}
+		}
+		if result.Suggestion != "" {
+			fmt.Fprintf(sb, "  hint: %s\n", result.Suggestion)
+		}
+	}
+	return sb.String()
+}
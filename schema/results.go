@@ -0,0 +1,369 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationResult is a violation of the schema, located in the YAML source of
+// the config.
+type ValidationResult struct {
+	// Path is the dot separated path of the offending key, e.g.
+	// "users.0.name", or empty for the whole config.
+	Path string `json:"path"`
+	// Line and Column where Path is defined in the source, 0 if unknown.
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	// Suggestion is a likely fix, e.g. `did you mean "ssh_authorized_keys"?`
+	// for an unknown key in a schema that forbids them, or in Lint.
+	Suggestion string `json:"suggestion,omitempty"`
+	// Severity is only set by Lint, violations of the schema are errors.
+	Severity Severity `json:"severity,omitempty"`
+}
+
+func (r ValidationResult) String() string {
+	sb := &strings.Builder{}
+	if r.Line > 0 {
+		fmt.Fprintf(sb, "%d:%d: ", r.Line, r.Column)
+	}
+	if r.Severity != "" {
+		fmt.Fprintf(sb, "%s: ", r.Severity)
+	}
+	if r.Path != "" {
+		fmt.Fprintf(sb, "%s: ", r.Path)
+	}
+	sb.WriteString(r.Message)
+	if r.Suggestion != "" {
+		fmt.Fprintf(sb, " (%s)", r.Suggestion)
+	}
+	return sb.String()
+}
+
+// ValidationResults are the violations found in a config. Validate returns
+// them as an error.
+type ValidationResults []ValidationResult
+
+func (r ValidationResults) Error() string {
+	lines := make([]string, len(r))
+	for i, result := range r {
+		lines[i] = result.String()
+	}
+	return fmt.Sprintf("invalid configuration:\n%s", strings.Join(lines, "\n"))
+}
+
+// Results validates the config and returns its violations, located in the
+// Source and with suggestions to fix them when possible, or nil if the config
+// is valid. Schemas like RootSchema allow additional keys, so unknown keys are
+// only violations, with the known keys as suggestions, in the ones that
+// forbid them. Lint flags them for any schema.
+func (kc *KConfig) Results() (ValidationResults, error) {
+	return kc.results(false)
+}
+
+// PartialResults is like Results but missing required keys are not
+// violations, as the config is only a part of the final one, e.g. one of the
+// sources merged by the collector.
+func (kc *KConfig) PartialResults() (ValidationResults, error) {
+	return kc.results(true)
+}
+
+func (kc *KConfig) results(partial bool) (ValidationResults, error) {
+	if kc.IsValid() {
+		return nil, nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(kc.ValidationError, &ve) {
+		return nil, kc.ValidationError
+	}
+
+	generatedSchemaJSON, err := kc.jsonSchema()
+	if err != nil {
+		return nil, err
+	}
+	var root interface{}
+	if err := json.Unmarshal([]byte(generatedSchemaJSON), &root); err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(kc.Source), &doc); err != nil {
+		return nil, err
+	}
+
+	violations := leafViolations(ve, partial)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	results := ValidationResults{}
+	for _, v := range violations {
+		results = append(results, resultsOf(v, root, &doc)...)
+	}
+	return results, nil
+}
+
+// leafViolations returns the errors without causes, but the missing required
+// keys if partial is true. When none of the alternatives of a oneOf or anyOf
+// matches, the errors of all of them are only noise, so just the ones of the
+// closest alternative, with the fewest errors, are returned.
+func leafViolations(ve *jsonschema.ValidationError, partial bool) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 {
+		if partial && strings.HasSuffix(ve.KeywordLocation, "/required") {
+			return nil
+		}
+		return []*jsonschema.ValidationError{ve}
+	}
+
+	if strings.HasSuffix(ve.KeywordLocation, "/oneOf") || strings.HasSuffix(ve.KeywordLocation, "/anyOf") {
+		var closest []*jsonschema.ValidationError
+		for i, alternative := range ve.Causes {
+			violations := leafViolations(alternative, partial)
+			if i == 0 || len(violations) < len(closest) {
+				closest = violations
+			}
+		}
+		return closest
+	}
+
+	result := []*jsonschema.ValidationError{}
+	for _, cause := range ve.Causes {
+		result = append(result, leafViolations(cause, partial)...)
+	}
+	return result
+}
+
+// resultsOf turns a violation into results. Unknown and missing keys and
+// values not in an enum get a suggestion from the keys or values in the schema.
+func resultsOf(v *jsonschema.ValidationError, root interface{}, doc *yaml.Node) ValidationResults {
+	path := pointerToPath(v.InstanceLocation)
+	at, value := locate(doc, path)
+	result := ValidationResult{Path: strings.Join(path, "."), Message: v.Message}
+	if at != nil {
+		result.Line, result.Column = at.Line, at.Column
+	}
+
+	keyword, parent := keywordOf(root, v.AbsoluteKeywordLocation)
+	properties := propertiesOf(parent)
+	switch keyword {
+	case "additionalProperties":
+		results := ValidationResults{}
+		for _, key := range unknownKeys(value, properties) {
+			results = append(results, unknownKey(path, key, properties))
+		}
+		if len(results) > 0 {
+			return results
+		}
+	case "required":
+		unknown := []string{}
+		for _, key := range unknownKeys(value, properties) {
+			unknown = append(unknown, key.Value)
+		}
+		required, _ := parent["required"].([]interface{})
+		for _, r := range required {
+			name, _ := r.(string)
+			if c := closest(name, unknown); c != "" {
+				result.Suggestion = fmt.Sprintf("did you mean %q instead of %q?", name, c)
+				break
+			}
+		}
+	case "enum":
+		candidates := []string{}
+		values, _ := parent["enum"].([]interface{})
+		for _, e := range values {
+			if s, ok := e.(string); ok {
+				candidates = append(candidates, s)
+			}
+		}
+		if value != nil && value.Kind == yaml.ScalarNode {
+			if c := closest(value.Value, candidates); c != "" && c != value.Value {
+				result.Suggestion = fmt.Sprintf("did you mean %q?", c)
+			}
+		}
+	}
+	return ValidationResults{result}
+}
+
+// unknownKey returns the result of a key of the mapping at the path which is
+// not in properties, suggesting the closest one.
+func unknownKey(path []string, key *yaml.Node, properties []string) ValidationResult {
+	r := ValidationResult{
+		Path:    strings.Join(append(append([]string{}, path...), key.Value), "."),
+		Line:    key.Line,
+		Column:  key.Column,
+		Message: fmt.Sprintf("unknown key %q", key.Value),
+	}
+	if c := closest(key.Value, properties); c != "" {
+		r.Suggestion = fmt.Sprintf("did you mean %q?", c)
+	}
+	return r
+}
+
+// keywordOf returns the keyword of the absolute keyword location of a
+// violation, e.g. "required", and the schema that contains it.
+func keywordOf(root interface{}, location string) (string, map[string]interface{}) {
+	_, pointer, _ := strings.Cut(location, "#")
+	segments := pointerToPath(pointer)
+	if len(segments) == 0 {
+		return "", nil
+	}
+
+	node := root
+	for _, s := range segments[:len(segments)-1] {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			node = n[s]
+		case []interface{}:
+			i, err := strconv.Atoi(s)
+			if err != nil || i >= len(n) {
+				return "", nil
+			}
+			node = n[i]
+		default:
+			return "", nil
+		}
+	}
+	parent, _ := node.(map[string]interface{})
+	return segments[len(segments)-1], parent
+}
+
+// propertiesOf returns the sorted names of the properties of a schema.
+func propertiesOf(s map[string]interface{}) []string {
+	properties, _ := s["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unknownKeys returns the keys of the mapping which are not in properties.
+func unknownKeys(mapping *yaml.Node, properties []string) []*yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, p := range properties {
+		known[p] = true
+	}
+	result := []*yaml.Node{}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !known[mapping.Content[i].Value] {
+			result = append(result, mapping.Content[i])
+		}
+	}
+	return result
+}
+
+// pointerToPath turns a JSON pointer (e.g. "/users/0/name") into its segments
+// (e.g. "users", "0", "name").
+func pointerToPath(pointer string) []string {
+	pointer = strings.TrimPrefix(pointer, "/")
+	if pointer == "" {
+		return nil
+	}
+	segments := strings.Split(pointer, "/")
+	for i, s := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+	}
+	return segments
+}
+
+// locate returns the node where the path is defined in the YAML document,
+// which is the key of a mapping or the item of a sequence, and its value. If
+// the path doesn't exist, it returns where its closest parent is defined and
+// a nil value.
+func locate(doc *yaml.Node, path []string) (at, value *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	node := doc.Content[0]
+	at = node
+	for _, segment := range path {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					at = node.Content[i]
+					next = node.Content[i+1]
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(segment); err == nil && i < len(node.Content) {
+				next = node.Content[i]
+				at = next
+			}
+		}
+		if next == nil {
+			return at, nil
+		}
+		node = next
+	}
+	return at, node
+}
+
+// Locate returns the line and column where the path, e.g. "users", "0",
+// "name", is defined in the YAML content, or where its closest parent is if it
+// doesn't exist. Both are 0 if the content can't be parsed.
+func Locate(content []byte, path []string) (line, column int) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return 0, 0
+	}
+	at, _ := locate(&doc, path)
+	if at == nil {
+		return 0, 0
+	}
+	return at.Line, at.Column
+}
+
+// closest returns the candidate most similar to the word, or "" if none is
+// close enough to be a typo of it.
+func closest(word string, candidates []string) string {
+	maxDistance := len(word) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	if maxDistance > 3 {
+		maxDistance = 3
+	}
+
+	best, bestDistance := "", maxDistance+1
+	for _, c := range candidates {
+		if d := distance(strings.ToLower(word), strings.ToLower(c)); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between two strings, the number of
+// runes to insert, remove or replace to turn one into the other.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,162 @@
+package schema_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type strictUserSchema struct {
+	_                 struct{} `additionalProperties:"false"`
+	Name              string   `json:"name,omitempty"`
+	SSHAuthorizedKeys []string `json:"ssh_authorized_keys,omitempty"`
+}
+
+var _ = Describe("Results", func() {
+	results := func(yaml string, schemaType interface{}) ValidationResults {
+		config, err := NewConfigFromYAML(yaml, schemaType)
+		Expect(err).ToNot(HaveOccurred())
+		r, err := config.Results()
+		Expect(err).ToNot(HaveOccurred())
+		return r
+	}
+
+	It("returns nil for valid configs", func() {
+		Expect(results("#cloud-config\nusers:\n- name: kairos\n", RootSchema{})).To(BeNil())
+	})
+
+	It("locates the violations in the source", func() {
+		Expect(results(`#cloud-config
+users:
+  - name: 007
+    passwd: kairos
+install:
+  device: foobar
+`, RootSchema{})).To(ConsistOf(
+			ValidationResult{Path: "users.0.name", Line: 3, Column: 5, Message: "expected string, but got number"},
+			ValidationResult{Path: "install.device", Line: 6, Column: 3, Message: "does not match pattern '^(auto|/|(/[a-zA-Z0-9_-]+)+)$'"},
+		))
+	})
+
+	It("only returns the violations of the closest alternative of a oneOf", func() {
+		Expect(results(`#cloud-config
+users:
+- name: kairos
+install:
+  reboot: true
+  poweroff: true
+`, RootSchema{})).To(Equal(ValidationResults{
+			{Path: "install.poweroff", Line: 6, Column: 3, Message: "value must be false"},
+		}))
+	})
+
+	It("suggests the key of a missing required key", func() {
+		Expect(results("#cloud-config\nuser:\n- name: kairos\n", RootSchema{})).To(Equal(ValidationResults{
+			{Line: 2, Column: 1, Message: "missing properties: 'users'", Suggestion: `did you mean "users" instead of "user"?`},
+		}))
+	})
+
+	It("suggests the known keys for unknown ones", func() {
+		Expect(results("name: kairos\nssh_authorized_key: [github:mudler]\nfoo: bar\n", strictUserSchema{})).To(Equal(ValidationResults{
+			{Path: "ssh_authorized_key", Line: 2, Column: 1, Message: `unknown key "ssh_authorized_key"`, Suggestion: `did you mean "ssh_authorized_keys"?`},
+			{Path: "foo", Line: 3, Column: 1, Message: `unknown key "foo"`},
+		}))
+	})
+
+	It("only suggests the known keys in Lint when the schema allows unknown ones", func() {
+		config := "#cloud-config\nusers:\n- name: kairos\n  ssh_authorized_key: [github:mudler]\n"
+		Expect(results(config, RootSchema{})).To(BeNil())
+
+		r, err := Lint(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r).To(Equal(ValidationResults{
+			{Path: "users.0.ssh_authorized_key", Line: 4, Column: 3, Message: `unknown key "ssh_authorized_key"`, Suggestion: `did you mean "ssh_authorized_keys"?`, Severity: SeverityError},
+		}))
+	})
+
+	It("ignores missing required keys in partial results", func() {
+		kc, err := NewConfigFromYAML("#cloud-config\ninstall:\n  reboot: true\n  poweroff: true\n", RootSchema{})
+		Expect(err).ToNot(HaveOccurred())
+		r, err := kc.PartialResults()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r).To(Equal(ValidationResults{
+			{Path: "install.poweroff", Line: 4, Column: 3, Message: "value must be false"},
+		}))
+		Expect(kc.Results()).To(HaveLen(2))
+
+		kc, err = NewConfigFromYAML("#cloud-config\ninstall:\n  reboot: true\n", RootSchema{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kc.PartialResults()).To(BeNil())
+	})
+
+	It("suggests the values of an enum", func() {
+		r := results("role: mastr\nnetwork_token: token\n", P2PSchema{})
+		Expect(r).To(HaveLen(1))
+		Expect(r[0].Path).To(Equal("role"))
+		Expect(r[0].Suggestion).To(Equal(`did you mean "master"?`))
+	})
+
+	It("is returned by Validate", func() {
+		err := Validate("#cloud-config\nusers:\n- name: 007\n")
+		Expect(err).To(BeAssignableToTypeOf(ValidationResults{}))
+		Expect(err).To(MatchError("invalid configuration:\n3:3: users.0.name: expected string, but got number"))
+	})
+
+	Describe("Locate", func() {
+		It("returns the position of a path", func() {
+			content := []byte("#cloud-config\nusers:\n- name: kairos\n  groups: [admin]\n")
+			line, column := Locate(content, []string{"users", "0", "groups"})
+			Expect([]int{line, column}).To(Equal([]int{4, 3}))
+			line, column = Locate(content, []string{"users", "0", "missing"})
+			Expect([]int{line, column}).To(Equal([]int{3, 3}))
+			line, column = Locate([]byte("- ["), []string{"users"})
+			Expect([]int{line, column}).To(Equal([]int{0, 0}))
+		})
+	})
+
+	Describe("Render", func() {
+		source := "#cloud-config\ninstall:\n  device: foobar\n"
+		r := ValidationResults{
+			{Path: "install.device", Line: 3, Column: 3, Message: "invalid device", Suggestion: `did you mean "auto"?`},
+			{Message: "missing properties: 'users'"},
+		}
+
+		It("renders them for terminals", func() {
+			buf := &bytes.Buffer{}
+			Expect(r.Render(buf, RenderPretty, source)).To(Succeed())
+			Expect(buf.String()).To(Equal(`error: install.device: invalid device
+  --> line 3, column 3
+   3 |   device: foobar
+     |   ^
+  hint: did you mean "auto"?
+
+error: missing properties: 'users'
+`))
+		})
+
+		It("renders them as JSON", func() {
+			buf := &bytes.Buffer{}
+			Expect(r.Render(buf, RenderJSON, source)).To(Succeed())
+			var decoded []map[string]interface{}
+			Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded[0]).To(Equal(map[string]interface{}{
+				"path": "install.device", "line": 3.0, "column": 3.0, "message": "invalid device", "suggestion": `did you mean "auto"?`,
+			}))
+
+			buf.Reset()
+			Expect(ValidationResults(nil).Render(buf, RenderJSON, "")).To(Succeed())
+			Expect(buf.String()).To(Equal("[]\n"))
+		})
+
+		It("parses the format", func() {
+			f, err := ParseRenderFormat("json")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(RenderJSON))
+			_, err = ParseRenderFormat("xml")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -2,8 +2,11 @@ package schema
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 
+	"github.com/kairos-io/kairos-sdk/clusterplugin"
+	yipschema "github.com/mudler/yip/pkg/schema"
 	"github.com/santhosh-tekuri/jsonschema/v5"
 	jsonschemago "github.com/swaggest/jsonschema-go"
 	"gopkg.in/yaml.v3"
@@ -14,26 +17,36 @@ type RootSchema struct {
 	_                         struct{}       `title:"Kairos Schema" description:"Defines all valid Kairos configuration attributes."`
 	Bundles                   []BundleSchema `json:"bundles,omitempty" description:"Add bundles in runtime"`
 	ConfigURL                 string         `json:"config_url,omitempty" description:"URL download configuration from."`
+	ConfigURLSHA256           string         `json:"config_url_sha256,omitempty" pattern:"^[a-fA-F0-9]{64}$" description:"Expected sha256 checksum of the configuration downloaded from config_url." example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
 	Env                       []string       `json:"env,omitempty"`
 	FailOnBundleErrors        bool           `json:"fail_on_bundles_errors,omitempty"`
 	GrubOptionsSchema         `json:"grub_options,omitempty"`
-	Install                   InstallSchema  `json:"install,omitempty"`
-	Options                   []interface{}  `json:"options,omitempty" description:"Various options."`
-	Users                     []UserSchema   `json:"users,omitempty" minItems:"1" required:"true"`
-	P2P                       P2PSchema      `json:"p2p,omitempty"`
-	Debug                     bool           `json:"debug,omitempty" mapstructure:"debug"`
-	Strict                    bool           `json:"strict,omitempty" mapstructure:"strict"`
-	CloudInitPaths            []string       `json:"cloud-init-paths,omitempty" mapstructure:"cloud-init-paths"`
-	EjectCD                   bool           `json:"eject-cd,omitempty" mapstructure:"eject-cd"`
-	FullCloudConfig           string         `json:"fullcloudconfig,omitempty" mapstructure:"fullcloudconfig"`
-	Cosign                    bool           `json:"cosign,omitempty" mapstructure:"cosign"`
-	Verify                    bool           `json:"verify,omitempty" mapstructure:"verify"`
-	CosignPubKey              string         `json:"cosign-key,omitempty" mapstructure:"cosign-key"`
-	Arch                      string         `json:"arch,omitempty" mapstructure:"arch"`
-	Platform                  PlatformSchema `json:"platform,omitempty" mapstructure:"platform"`
-	SquashFsCompressionConfig []string       `json:"squash-compression,omitempty" mapstructure:"squash-compression"`
-	SquashFsNoCompression     bool           `json:"squash-no-compression,omitempty" mapstructure:"squash-no-compression"`
-	UkiMaxEntries             int            `json:"uki-max-entries,omitempty" mapstructure:"uki-max-entries"`
+	Install                   InstallSchema         `json:"install,omitempty"`
+	Options                   []interface{}         `json:"options,omitempty" description:"Various options."`
+	Users                     []UserSchema          `json:"users,omitempty" minItems:"1" required:"true"`
+	P2P                       P2PSchema             `json:"p2p,omitempty"`
+	Debug                     bool                  `json:"debug,omitempty" mapstructure:"debug"`
+	Strict                    bool                  `json:"strict,omitempty" mapstructure:"strict"`
+	CloudInitPaths            []string              `json:"cloud-init-paths,omitempty" mapstructure:"cloud-init-paths"`
+	EjectCD                   bool                  `json:"eject-cd,omitempty" mapstructure:"eject-cd"`
+	FullCloudConfig           string                `json:"fullcloudconfig,omitempty" mapstructure:"fullcloudconfig"`
+	Cosign                    bool                  `json:"cosign,omitempty" mapstructure:"cosign"`
+	Verify                    bool                  `json:"verify,omitempty" mapstructure:"verify"`
+	CosignPubKey              string                `json:"cosign-key,omitempty" mapstructure:"cosign-key"`
+	Arch                      string                `json:"arch,omitempty" mapstructure:"arch"`
+	Platform                  PlatformSchema        `json:"platform,omitempty" mapstructure:"platform"`
+	SquashFsCompressionConfig []string              `json:"squash-compression,omitempty" mapstructure:"squash-compression"`
+	SquashFsNoCompression     bool                  `json:"squash-no-compression,omitempty" mapstructure:"squash-no-compression"`
+	UkiMaxEntries             int                   `json:"uki-max-entries,omitempty" mapstructure:"uki-max-entries"`
+	Bootloader                BootloaderSchema      `json:"bootloader,omitempty"`
+	Hostname                  string                `json:"hostname,omitempty" description:"Hostname of the machine, it can use templates like {{ trunc 4 .MachineID }}" example:"kairos-{{ trunc 4 .Random }}"`
+	Stages                    StagesSchema          `json:"stages,omitempty" description:"yip stages to run, by stage name"`
+	K3s                       K3sSchema             `json:"k3s,omitempty"`
+	K3sAgent                  K3sSchema             `json:"k3s-agent,omitempty"`
+	Kcrypt                    KcryptSchema          `json:"kcrypt,omitempty"`
+	Upgrade                   UpgradeSchema         `json:"upgrade,omitempty"`
+	Reset                     ResetSchema           `json:"reset,omitempty"`
+	Cluster                   clusterplugin.Cluster `json:"cluster,omitempty" description:"Configures the cluster set up by the provider, see clusterplugin"`
 }
 
 type PlatformSchema struct {
@@ -48,6 +61,9 @@ type KConfig struct {
 	parsed          interface{}
 	ValidationError error
 	schemaType      interface{}
+	// schemaJSON is used instead of the schema of schemaType when set, see
+	// NewVersionedConfigFromYAML.
+	schemaJSON string
 }
 
 // GenerateSchema takes the given schema type and builds a JSON Schema out of it
@@ -55,8 +71,17 @@ type KConfig struct {
 // defining a version of a Root Schema which will be available online.
 func GenerateSchema(schemaType interface{}, url string) (string, error) {
 	reflector := jsonschemago.Reflector{}
+	for src, dst := range stageTypeMappings {
+		reflector.AddTypeMapping(src, dst)
+	}
 
-	generatedSchema, err := reflector.Reflect(schemaType)
+	generatedSchema, err := reflector.Reflect(schemaType,
+		// The configuration is YAML, so the yaml tags of the types reused from
+		// other packages, like yip stages, win over their json tags.
+		jsonschemago.PropertyNameTag("yaml", "json"),
+		jsonschemago.InterceptProp(interceptWriteOnly),
+		jsonschemago.InterceptDefName(yipDefName),
+	)
 	if err != nil {
 		return "", err
 	}
@@ -72,8 +97,52 @@ func GenerateSchema(schemaType interface{}, url string) (string, error) {
 	return string(generatedSchemaJSON), nil
 }
 
+// interceptWriteOnly adds the writeOnly keyword to the fields tagged with
+// writeOnly:"true", which is not supported by the reflector, and to the
+// writeOnlyFields. It marks values that are sensitive and must not be shown,
+// see SensitivePaths.
+func interceptWriteOnly(params jsonschemago.InterceptPropParams) error {
+	if params.Processed && isWriteOnly(params) {
+		params.PropertySchema.WithExtraPropertiesItem("writeOnly", true)
+	}
+	return nil
+}
+
+// writeOnlyFields are the sensitive fields of the types reused from other
+// packages, which can't be tagged with writeOnly:"true".
+var writeOnlyFields = map[reflect.Type][]string{
+	reflect.TypeOf(yipschema.User{}):        {"PasswordHash"},
+	reflect.TypeOf(yipschema.Auth{}):        {"Password", "PrivateKey"},
+	reflect.TypeOf(clusterplugin.Cluster{}): {"ClusterToken"},
+}
+
+// isWriteOnly returns true if the field is tagged with writeOnly:"true" or is
+// one of the writeOnlyFields of the parent type.
+func isWriteOnly(params jsonschemago.InterceptPropParams) bool {
+	if params.Field.Tag.Get("writeOnly") == "true" {
+		return true
+	}
+	if params.ParentSchema == nil || params.ParentSchema.ReflectType == nil {
+		return false
+	}
+	for _, name := range writeOnlyFields[params.ParentSchema.ReflectType] {
+		if params.Field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSchema returns the JSON Schema the config is validated against.
+func (kc *KConfig) jsonSchema() (string, error) {
+	if kc.schemaJSON != "" {
+		return kc.schemaJSON, nil
+	}
+	return GenerateSchema(kc.schemaType, "")
+}
+
 func (kc *KConfig) validate() {
-	generatedSchemaJSON, err := GenerateSchema(kc.schemaType, "")
+	generatedSchemaJSON, err := kc.jsonSchema()
 	if err != nil {
 		kc.ValidationError = err
 		return
@@ -71,6 +71,46 @@ users:
 				Expect(config.HasHeader()).To(BeTrue())
 			})
 		})
+
+		Context("With the cluster and k3s blocks", func() {
+			BeforeEach(func() {
+				yaml = `#cloud-config
+hostname: kairos-{{ trunc 4 .Random }}
+users:
+  - name: kairos
+    passwd: kairos
+cluster:
+  cluster_token: token
+  role: init
+  cluster_config_path: /etc/cluster.yaml
+  providerConfig:
+    foo: bar
+k3s-agent:
+  enabled: true`
+			})
+
+			It("is valid", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(config.IsValid()).To(BeTrue())
+			})
+		})
+
+		Context("With a wrong type in the cluster block", func() {
+			BeforeEach(func() {
+				yaml = `#cloud-config
+users:
+  - name: kairos
+    passwd: kairos
+cluster:
+  import_local_images: "yes"`
+			})
+
+			It("errors", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(config.IsValid()).NotTo(BeTrue())
+				Expect(config.ValidationError.Error()).To(ContainSubstring("/cluster/import_local_images"))
+			})
+		})
 	})
 
 	Context("GenerateSchema", func() {
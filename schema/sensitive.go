@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// SensitivePaths returns the dot separated paths of the values marked as
+// writeOnly in the schema of the given type, e.g. "users.*.passwd". Items of
+// lists and values of maps are matched by "*".
+func SensitivePaths(schemaType interface{}) ([]string, error) {
+	generatedSchemaJSON, err := GenerateSchema(schemaType, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(generatedSchemaJSON), &root); err != nil {
+		return nil, err
+	}
+	definitions, _ := root["definitions"].(map[string]interface{})
+
+	found := map[string]bool{}
+	var walk func(node map[string]interface{}, path []string, seen map[string]bool)
+	walk = func(node map[string]interface{}, path []string, seen map[string]bool) {
+		if ref, ok := node["$ref"].(string); ok {
+			name := strings.TrimPrefix(ref, "#/definitions/")
+			// Recursive definitions don't add anything new.
+			if seen[name] {
+				return
+			}
+			if def, ok := definitions[name].(map[string]interface{}); ok {
+				nested := map[string]bool{name: true}
+				for k := range seen {
+					nested[k] = true
+				}
+				walk(def, path, nested)
+			}
+		}
+		if writeOnly, _ := node["writeOnly"].(bool); writeOnly && len(path) > 0 {
+			found[strings.Join(path, ".")] = true
+		}
+
+		if properties, ok := node["properties"].(map[string]interface{}); ok {
+			for name, p := range properties {
+				if property, ok := p.(map[string]interface{}); ok {
+					walk(property, append(append([]string{}, path...), name), seen)
+				}
+			}
+		}
+		for _, keyword := range []string{"items", "additionalProperties"} {
+			if item, ok := node[keyword].(map[string]interface{}); ok {
+				walk(item, append(append([]string{}, path...), "*"), seen)
+			}
+		}
+		for _, keyword := range []string{"oneOf", "anyOf", "allOf"} {
+			alternatives, _ := node[keyword].([]interface{})
+			for _, a := range alternatives {
+				if alternative, ok := a.(map[string]interface{}); ok {
+					walk(alternative, path, seen)
+				}
+			}
+		}
+	}
+	walk(root, nil, map[string]bool{})
+
+	paths := make([]string, 0, len(found))
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,37 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SensitivePaths", func() {
+	It("returns the paths marked as writeOnly", func() {
+		paths, err := SensitivePaths(RootSchema{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paths).To(ContainElements("users.*.passwd", "p2p.network_token", "cluster.cluster_token",
+			"stages.*.*.users.*.passwd", "stages.*.*.git.auth.password",
+			"bundles.*.auth.password", "bundles.*.auth.auth", "bundles.*.auth.identitytoken", "bundles.*.auth.registrytoken",
+			"install.bundles.*.auth.password"))
+		Expect(paths).ToNot(ContainElements("bundles.*.auth.username", "bundles.*.auth.serveraddress"))
+	})
+
+	It("follows lists and maps of nested types", func() {
+		type secret struct {
+			Value string `json:"value" writeOnly:"true"`
+		}
+		type config struct {
+			Token   string            `json:"token" writeOnly:"true"`
+			Name    string            `json:"name"`
+			List    []secret          `json:"list"`
+			Map     map[string]secret `json:"map"`
+			Nested  *secret           `json:"nested"`
+			Ignored secret            `json:"-"`
+		}
+
+		paths, err := SensitivePaths(config{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paths).To(Equal([]string{"list.*.value", "map.*.value", "nested.value", "token"}))
+	})
+})
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"reflect"
+
+	yipschema "github.com/mudler/yip/pkg/schema"
+)
+
+// StagesSchema represents the stages block in the Kairos configuration. It runs yip stages, like creating files or users, at the different stages of the boot, e.g. "initramfs", "boot" or "network", and their ".before" and ".after" variants.
+type StagesSchema map[string][]yipschema.Stage
+
+// FileSchema represents the files of a stage. yip decodes them with the lowercase names of the fields, which the reflector can't get from yipschema.File as it has no tags.
+type FileSchema struct {
+	Path        string `json:"path,omitempty" description:"Path of the file"`
+	Permissions uint32 `json:"permissions,omitempty" description:"Permissions of the file, in octal" example:"0644"`
+	Owner       int    `json:"owner,omitempty" description:"UID of the owner of the file"`
+	Group       int    `json:"group,omitempty" description:"GID of the group of the file"`
+	Content     string `json:"content,omitempty"`
+	Encoding    string `json:"encoding,omitempty" enum:"[\"\",\"b64\",\"base64\",\"gz\",\"gzip\",\"gz+base64\",\"gzip+base64\",\"gz+b64\",\"gzip+b64\"]" description:"Encoding of the content"`
+	OwnerString string `json:"ownerstring,omitempty" description:"Owner of the file as user:group, instead of owner and group"`
+}
+
+// DownloadSchema represents the downloads of a stage, see FileSchema.
+type DownloadSchema struct {
+	Path        string `json:"path,omitempty" description:"Path to download the file to"`
+	URL         string `json:"url,omitempty"`
+	Permissions uint32 `json:"permissions,omitempty" description:"Permissions of the file, in octal" example:"0644"`
+	Owner       int    `json:"owner,omitempty"`
+	Group       int    `json:"group,omitempty"`
+	Timeout     int    `json:"timeout,omitempty" minimum:"0" description:"Timeout of the download, in seconds"`
+	OwnerString string `json:"ownerstring,omitempty"`
+}
+
+// DirectorySchema represents the directories of a stage, see FileSchema.
+type DirectorySchema struct {
+	Path        string `json:"path,omitempty"`
+	Permissions uint32 `json:"permissions,omitempty" description:"Permissions of the directory, in octal" example:"0755"`
+	Owner       int    `json:"owner,omitempty"`
+	Group       int    `json:"group,omitempty"`
+}
+
+// stageTypeMappings replaces the yip types without tags in the generated schema.
+var stageTypeMappings = map[interface{}]interface{}{
+	yipschema.File{}:      FileSchema{},
+	yipschema.Download{}:  DownloadSchema{},
+	yipschema.Directory{}: DirectorySchema{},
+}
+
+// yipDefName names the definitions of the yip types after yip, as the package
+// is also called schema, e.g. "YipStage" instead of "SchemaStage".
+func yipDefName(t reflect.Type, defaultDefName string) string {
+	if t.PkgPath() == reflect.TypeOf(yipschema.Stage{}).PkgPath() {
+		return "Yip" + t.Name()
+	}
+	return defaultDefName
+}
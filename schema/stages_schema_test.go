@@ -0,0 +1,72 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stages Schema", func() {
+	var config *KConfig
+	var err error
+	var yaml string
+
+	JustBeforeEach(func() {
+		config, err = NewConfigFromYAML(yaml, StagesSchema{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with yip stages", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+initramfs:
+- name: Set up the user
+  users:
+    kairos:
+      passwd: kairos
+      groups: [admin]
+boot.after:
+- files:
+  - path: /etc/motd
+    permissions: 0644
+    content: aGVsbG8=
+    encoding: b64
+  commands:
+  - echo hello`
+		})
+
+		It("succeeds", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("when a stage is not a list", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+boot:
+  commands:
+  - echo hello`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("expected array, but got object"))
+		})
+	})
+
+	Context("when a file has an unknown encoding", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+boot:
+- files:
+  - path: /etc/motd
+    content: hello
+    encoding: base32`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("/boot/0/files/0/encoding"))
+		})
+	})
+})
@@ -0,0 +1,22 @@
+package schema
+
+// UpgradeSchema represents the upgrade block in the Kairos configuration. It sets the defaults of upgrades, which can be overridden from the command line.
+type UpgradeSchema struct {
+	_ struct{} `title:"Kairos Schema: Upgrade block" description:"The upgrade block sets the defaults of upgrades."`
+	PowerManagement
+	Recovery       bool   `json:"recovery,omitempty" description:"Upgrade the recovery system instead of the active one"`
+	Force          bool   `json:"force,omitempty" description:"Upgrade even if the image is the same or older"`
+	GrubDefEntry   string `json:"grub-entry-name,omitempty" description:"Name of the boot entry"`
+	System         Image  `json:"system,omitempty" description:"Image to upgrade the active system to"`
+	RecoverySystem Image  `json:"recovery-system,omitempty" description:"Image to upgrade the recovery system to"`
+}
+
+// ResetSchema represents the reset block in the Kairos configuration. It sets the defaults of resets, which restore the active system from the recovery one.
+type ResetSchema struct {
+	_ struct{} `title:"Kairos Schema: Reset block" description:"The reset block sets the defaults of resets."`
+	PowerManagement
+	ResetPersistent bool   `json:"reset-persistent,omitempty" default:"true" description:"Format the persistent partition"`
+	ResetOEM        bool   `json:"reset-oem,omitempty" default:"false" description:"Format the OEM partition"`
+	GrubDefEntry    string `json:"grub-entry-name,omitempty" description:"Name of the boot entry"`
+	System          Image  `json:"system,omitempty" description:"Image to reset the active system to, the recovery one by default"`
+}
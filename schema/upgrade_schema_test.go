@@ -0,0 +1,82 @@
+package schema_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade Schema", func() {
+	var config *KConfig
+	var err error
+	var yaml string
+
+	JustBeforeEach(func() {
+		config, err = NewConfigFromYAML(yaml, UpgradeSchema{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with a system image", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+reboot: true
+system:
+  uri: oci:quay.io/kairos/kairos-ubuntu:latest
+recovery-system:
+  uri: oci:quay.io/kairos/kairos-ubuntu:latest`
+		})
+
+		It("succeeds", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("when reboot and poweroff are true", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+reboot: true
+poweroff: true`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("value must be false"))
+		})
+	})
+})
+
+var _ = Describe("Reset Schema", func() {
+	var config *KConfig
+	var err error
+	var yaml string
+
+	JustBeforeEach(func() {
+		config, err = NewConfigFromYAML(yaml, ResetSchema{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with the partitions to reset", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+reset-persistent: false
+reset-oem: true
+poweroff: true`
+		})
+
+		It("succeeds", func() {
+			Expect(config.IsValid()).To(BeTrue())
+		})
+	})
+
+	Context("when reset-oem is not a boolean", func() {
+		BeforeEach(func() {
+			yaml = `#cloud-config
+reset-oem: "yes"`
+		})
+
+		It("errors", func() {
+			Expect(config.IsValid()).NotTo(BeTrue())
+			Expect(config.ValidationError.Error()).To(ContainSubstring("expected boolean, but got string"))
+		})
+	})
+})
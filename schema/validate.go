@@ -8,11 +8,21 @@ import (
 	"strings"
 )
 
-// JSONSchema builds a JSON Schema based on the Root Schema and the given version
-// this is helpful when mapping a validation error.
+// SchemaURL returns the URL where the JSON Schema of the given version is
+// published.
+func SchemaURL(version string) string {
+	return fmt.Sprintf("https://kairos.io/%s/cloud-config.json", version)
+}
+
+// JSONSchema returns the JSON Schema of the given version from the registry, see
+// Versions, or builds one based on the Root Schema for other versions. This is
+// helpful when mapping a validation error.
 func JSONSchema(version string) (string, error) {
-	url := fmt.Sprintf("https://kairos.io/%s/cloud-config.json", version)
-	schema, err := GenerateSchema(RootSchema{}, url)
+	if v, ok := registeredVersion(version); ok {
+		return VersionedJSONSchema(v)
+	}
+
+	schema, err := GenerateSchema(RootSchema{}, SchemaURL(version))
 	if err != nil {
 		return "", err
 	}
@@ -21,32 +31,11 @@ func JSONSchema(version string) (string, error) {
 }
 
 // Validate ensures that a given schema is Valid according to the Root Schema from the agent.
+// The violations are returned as ValidationResults.
 func Validate(source string) error {
-	var yaml string
-
-	if strings.HasPrefix(source, "http") {
-		resp, err := http.Get(source)
-		if err != nil {
-			return err
-		}
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		//Convert the body to type string
-		yaml = string(body)
-	} else {
-		// Maybe we should just try to read the string for the normal headers? That would identify a full yaml vs a file
-		dat, err := os.ReadFile(source)
-		if err != nil {
-			if strings.Contains(err.Error(), "no such file or directory") || strings.Contains(err.Error(), "file name too long") {
-				yaml = source
-			} else {
-				return err
-			}
-		} else {
-			yaml = string(dat)
-		}
+	yaml, err := readSource(source)
+	if err != nil {
+		return err
 	}
 
 	config, err := NewConfigFromYAML(yaml, RootSchema{})
@@ -54,18 +43,64 @@ func Validate(source string) error {
 		return err
 	}
 
-	if !config.HasHeader() {
-		return fmt.Errorf("missing #cloud-config header")
+	return validateConfig(config)
+}
+
+// ValidateVersion is like Validate but validates against the schema of the
+// given version in the registry, see Versions.
+func ValidateVersion(source, version string) error {
+	yaml, err := readSource(source)
+	if err != nil {
+		return err
+	}
+
+	config, err := NewVersionedConfigFromYAML(yaml, version)
+	if err != nil {
+		return err
 	}
 
-	if config.IsValid() {
-		return nil
+	return validateConfig(config)
+}
+
+func validateConfig(config *KConfig) error {
+	if !config.HasHeader() {
+		return fmt.Errorf("missing #cloud-config header")
 	}
 
-	err = config.ValidationError
+	results, err := config.Results()
 	if err != nil {
 		return err
 	}
+	if len(results) > 0 {
+		return results
+	}
 
 	return nil
 }
+
+// readSource returns the config in the source, which is either a URL, a file
+// or the config itself.
+func readSource(source string) (string, error) {
+	if strings.HasPrefix(source, "http") {
+		resp, err := http.Get(source)
+		if err != nil {
+			return "", err
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		//Convert the body to type string
+		return string(body), nil
+	}
+
+	// Maybe we should just try to read the string for the normal headers? That would identify a full yaml vs a file
+	dat, err := os.ReadFile(source)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such file or directory") || strings.Contains(err.Error(), "file name too long") {
+			return source, nil
+		}
+		return "", err
+	}
+	return string(dat), nil
+}
@@ -8,11 +8,11 @@ import (
 	"strings"
 
 	"github.com/foxboron/go-uefi/efi"
-	"github.com/itchyny/gojq"
 	"github.com/jaypipes/ghw"
 	"github.com/jaypipes/ghw/pkg/block"
 	"github.com/kairos-io/kairos-sdk/signatures"
 	"github.com/kairos-io/kairos-sdk/types"
+	"github.com/kairos-io/kairos-sdk/unstructured"
 	"github.com/kairos-io/kairos-sdk/utils"
 	"github.com/rs/zerolog"
 	"github.com/zcalusic/sysinfo"
@@ -401,32 +401,22 @@ func (r Runtime) String() string {
 	return ""
 }
 
+// Query runs the jq expression, or dot separated path like "boot", on the
+// Runtime and returns the values it produces, strings as they are and any
+// other value as JSON.
 func (r Runtime) Query(s string) (res string, err error) {
-	s = fmt.Sprintf(".%s", s)
-	jsondata := map[string]interface{}{}
-	var dat []byte
-	dat, err = json.Marshal(r)
-	if err != nil {
-		return
-	}
-	err = json.Unmarshal(dat, &jsondata)
-	if err != nil {
-		return
-	}
-	query, err := gojq.Parse(s)
+	values, err := r.QueryValues(s)
 	if err != nil {
 		return res, err
 	}
-	iter := query.Run(jsondata) // or query.RunWithContext
-	for {
-		v, ok := iter.Next()
-		if !ok {
-			break
-		}
-		if err, ok := v.(error); ok {
-			return res, err
-		}
-		res += fmt.Sprint(v)
+	for _, v := range values {
+		res += v.String()
 	}
-	return
+	return res, nil
+}
+
+// QueryValues runs the jq expression, or dot separated path like "boot", on
+// the Runtime and returns the values it produces.
+func (r Runtime) QueryValues(expression string, opts ...unstructured.QueryOption) (unstructured.QueryValues, error) {
+	return unstructured.Query(expression, r, opts...)
 }
@@ -0,0 +1,233 @@
+package unstructured
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/itchyny/gojq"
+	"gopkg.in/yaml.v3"
+)
+
+// QueryOutput is the format QueryValues are rendered with.
+type QueryOutput string
+
+const (
+	// QueryOutputYAML renders every value as a YAML document.
+	QueryOutputYAML QueryOutput = "yaml"
+	// QueryOutputJSON renders every value as JSON, one per line.
+	QueryOutputJSON QueryOutput = "json"
+	// QueryOutputRaw renders strings as they are and other values as JSON,
+	// one per line, like jq --raw-output.
+	QueryOutputRaw QueryOutput = "raw"
+)
+
+// ParseQueryOutput returns the QueryOutput named s, e.g. the value of an
+// --output flag.
+func ParseQueryOutput(s string) (QueryOutput, error) {
+	switch o := QueryOutput(s); o {
+	case QueryOutputYAML, QueryOutputJSON, QueryOutputRaw:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown output %q, valid outputs are json, yaml and raw", s)
+}
+
+// QueryValue is one of the values produced by a query.
+type QueryValue struct {
+	v interface{}
+}
+
+// Interface returns the value as decoded from JSON: nil, bool, float64 or int,
+// string, []interface{} or map[string]interface{}.
+func (q QueryValue) Interface() interface{} {
+	return q.v
+}
+
+// IsNull returns true if the value is null, e.g. the key doesn't exist.
+func (q QueryValue) IsNull() bool {
+	return q.v == nil
+}
+
+// String returns strings as they are and any other value as JSON.
+func (q QueryValue) String() string {
+	if s, ok := q.v.(string); ok {
+		return s
+	}
+	dat, err := json.Marshal(q.v)
+	if err != nil {
+		return fmt.Sprint(q.v)
+	}
+	return string(dat)
+}
+
+// Bool returns the value if it's a boolean. The strings "true" and "false" are
+// accepted too, as that's how they are set from the cmdline.
+func (q QueryValue) Bool() (bool, error) {
+	switch t := q.v.(type) {
+	case bool:
+		return t, nil
+	case string:
+		if t == "true" || t == "false" {
+			return t == "true", nil
+		}
+	}
+	return false, fmt.Errorf("value is not a boolean: %s", q.String())
+}
+
+// Decode stores the value in the one pointed by target, as json.Unmarshal
+// would do.
+func (q QueryValue) Decode(target interface{}) error {
+	dat, err := json.Marshal(q.v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(dat, target)
+}
+
+// Format renders the value in the given output, see QueryOutput.
+func (q QueryValue) Format(output QueryOutput) (string, error) {
+	switch output {
+	case QueryOutputYAML:
+		dat, err := yaml.Marshal(q.v)
+		return string(dat), err
+	case QueryOutputJSON:
+		dat, err := json.Marshal(q.v)
+		return string(dat) + "\n", err
+	case QueryOutputRaw:
+		return q.String() + "\n", nil
+	}
+	return "", fmt.Errorf("unknown output %q", output)
+}
+
+// QueryValues are all the values produced by a query, in order.
+type QueryValues []QueryValue
+
+// First returns the first value or a null one if there are none.
+func (qs QueryValues) First() QueryValue {
+	if len(qs) == 0 {
+		return QueryValue{}
+	}
+	return qs[0]
+}
+
+// Format renders all the values one after the other, see QueryOutput.
+func (qs QueryValues) Format(output QueryOutput) (string, error) {
+	res := ""
+	for _, q := range qs {
+		s, err := q.Format(output)
+		if err != nil {
+			return res, err
+		}
+		res += s
+	}
+	return res, nil
+}
+
+type queryOptions struct {
+	variables map[string]interface{}
+}
+
+// QueryOption changes how a query is run.
+type QueryOption func(o *queryOptions)
+
+// QueryVariable makes the value available to the query as $name.
+func QueryVariable(name string, value interface{}) QueryOption {
+	return func(o *queryOptions) {
+		o.variables[strings.TrimPrefix(name, "$")] = value
+	}
+}
+
+// plainPath matches the queries that are a dot separated path without the
+// leading dot, e.g. "install.device", as accepted by Config.Query.
+var plainPath = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// Query runs the jq expression on data and returns all the values it produces.
+// For compatibility, expressions that don't start with "." or "$" are run on
+// the root of data, e.g. "install.device" or "users[0] | keys", and the empty
+// expression returns data. Data can be any value that can be marshalled to
+// JSON.
+func Query(expression string, data interface{}, opts ...QueryOption) (values QueryValues, err error) {
+	o := &queryOptions{variables: map[string]interface{}{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// The engine must never bring down the caller, whatever the expression.
+	defer func() {
+		if r := recover(); r != nil {
+			values, err = nil, fmt.Errorf("running query %q: %v", expression, r)
+		}
+	}()
+
+	switch {
+	case strings.TrimSpace(expression) == "":
+		expression = "."
+	case plainPath.MatchString(expression):
+		// The segments are quoted, so keys like "cosign-key" are not taken for
+		// a subtraction.
+		segments := strings.Split(expression, ".")
+		for i, s := range segments {
+			segments[i] = fmt.Sprintf(".%q", s)
+		}
+		expression = strings.Join(segments, "")
+	case !strings.HasPrefix(expression, ".") && !strings.HasPrefix(expression, "$"):
+		expression = "." + expression
+	}
+
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("parsing query: %w", err)
+	}
+
+	names := make([]string, 0, len(o.variables))
+	for name := range o.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	variables := make([]string, len(names))
+	variableValues := make([]interface{}, len(names))
+	for i, name := range names {
+		variables[i] = "$" + name
+		if variableValues[i], err = normalize(o.variables[name]); err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", name, err)
+		}
+	}
+
+	code, err := gojq.Compile(query, gojq.WithVariables(variables))
+	if err != nil {
+		return nil, fmt.Errorf("compiling query: %w", err)
+	}
+
+	input, err := normalize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	iter := code.Run(input, variableValues...)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return values, fmt.Errorf("running query: %w", err)
+		}
+		values = append(values, QueryValue{v: v})
+	}
+	return values, nil
+}
+
+// normalize turns v into the types gojq works with by marshalling it to JSON.
+func normalize(v interface{}) (interface{}, error) {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("converting data to JSON: %w", err)
+	}
+	var result interface{}
+	if err := json.Unmarshal(dat, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
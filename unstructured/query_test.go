@@ -0,0 +1,132 @@
+package unstructured_test
+
+import (
+	. "github.com/kairos-io/kairos-sdk/unstructured"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query", Label("unstructured-test"), func() {
+	var data map[string]interface{}
+
+	BeforeEach(func() {
+		data = map[string]interface{}{
+			"install": map[string]interface{}{
+				"device": "/dev/sda",
+				"auto":   true,
+			},
+			"cosign-key": "key.pub",
+			"users": []interface{}{
+				map[string]interface{}{"name": "kairos", "groups": []interface{}{"admin"}},
+				map[string]interface{}{"name": "guest"},
+			},
+			"reboot": "true",
+		}
+	})
+
+	It("accepts paths without the leading dot", func() {
+		v, err := Query("install.device", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().String()).To(Equal("/dev/sda"))
+
+		v, err = Query("cosign-key", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().String()).To(Equal("key.pub"))
+	})
+
+	It("runs the expressions without the leading dot on the root", func() {
+		v, err := Query("users[0].name", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().String()).To(Equal("kairos"))
+
+		v, err = Query("users[0]", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Interface()).To(HaveKeyWithValue("name", "kairos"))
+
+		v, err = Query("install | keys", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Interface()).To(Equal([]interface{}{"auto", "device"}))
+
+		v, err = Query("install.device | length", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Interface()).To(Equal(8))
+	})
+
+	It("returns the whole data for the empty expression", func() {
+		v, err := Query("", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Interface()).To(HaveKeyWithValue("cosign-key", "key.pub"))
+	})
+
+	It("runs jq expressions", func() {
+		v, err := Query(".users[] | select(.groups) | .name", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v).To(HaveLen(1))
+		Expect(v.First().String()).To(Equal("kairos"))
+
+		v, err = Query(".install | keys", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Interface()).To(Equal([]interface{}{"auto", "device"}))
+	})
+
+	It("makes variables available to the expression", func() {
+		v, err := Query(".users[] | select(.name == $user) | .groups[0]", data, QueryVariable("user", "kairos"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().String()).To(Equal("admin"))
+	})
+
+	It("returns null for missing keys", func() {
+		v, err := Query("install.missing", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().IsNull()).To(BeTrue())
+		Expect(QueryValues{}.First().IsNull()).To(BeTrue())
+	})
+
+	It("returns typed values", func() {
+		v, err := Query("install.auto", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Bool()).To(BeTrue())
+
+		v, err = Query("reboot", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.First().Bool()).To(BeTrue())
+
+		v, err = Query("install.device", data)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = v.First().Bool()
+		Expect(err).To(HaveOccurred())
+
+		v, err = Query(".users[0]", data)
+		Expect(err).ToNot(HaveOccurred())
+		user := struct {
+			Name   string   `json:"name"`
+			Groups []string `json:"groups"`
+		}{}
+		Expect(v.First().Decode(&user)).To(Succeed())
+		Expect(user.Name).To(Equal("kairos"))
+		Expect(user.Groups).To(Equal([]string{"admin"}))
+	})
+
+	It("formats the values", func() {
+		v, err := Query(".install.device, .install.auto", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.Format(QueryOutputRaw)).To(Equal("/dev/sda\ntrue\n"))
+		Expect(v.Format(QueryOutputJSON)).To(Equal("\"/dev/sda\"\ntrue\n"))
+		Expect(v.Format(QueryOutputYAML)).To(Equal("/dev/sda\ntrue\n"))
+
+		o, err := ParseQueryOutput("json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(o).To(Equal(QueryOutputJSON))
+		_, err = ParseQueryOutput("xml")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error on invalid expressions", func() {
+		_, err := Query(".install[", data)
+		Expect(err).To(HaveOccurred())
+		_, err = Query("$undefined", data)
+		Expect(err).To(HaveOccurred())
+		_, err = Query(".install.device | error(\"boom\")", data)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -1,12 +1,16 @@
 package unstructured
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
 	"github.com/hashicorp/go-multierror"
 	"github.com/itchyny/gojq"
 	"gopkg.in/yaml.v3"
-	"strings"
 )
 
 func YAMLHasKey(query string, content []byte) (bool, error) {
@@ -104,27 +108,32 @@ func jq(command string, data map[string]interface{}) (map[string]interface{}, er
 	return make(map[string]interface{}), nil
 }
 
+// ToYAML turns a map of keys in dot notation, like "install.device", to YAML.
+// Keys can index lists, like "users[0].name". Values can be of any type that
+// can be marshalled to JSON, the strings "true" and "false" are booleans.
 func ToYAML(v map[string]interface{}) ([]byte, error) {
 	data := map[string]interface{}{}
 	var errs error
 
-	for k, value := range v {
-		prefix := "."
-		equal := "="
-		tmplKey := "%s"
-		tmplValue := "\"%s\""
-		
-		// If key has a dash we need to add quotes to it to avoid failure parsing ut
-		if strings.Contains(k, "-") {
-			tmplKey = "\"%s\""
-		}
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
+	for _, k := range keys {
+		value := v[k]
 		// support boolean types
 		if value == "true" || value == "false" {
-			tmplValue = "%s"
+			value = value == "true"
+		}
+		literal, err := json.Marshal(value)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
 		}
-		finalTemplate := prefix + tmplKey + equal + tmplValue
-		newData, err := jq(fmt.Sprintf(finalTemplate, k, value), data)
+
+		newData, err := jq(dotToPath(k)+"="+string(literal), data)
 		if err != nil {
 			errs = multierror.Append(errs, err)
 			continue
@@ -139,6 +148,23 @@ func ToYAML(v map[string]interface{}) ([]byte, error) {
 	return out, errs
 }
 
+var indexSuffix = regexp.MustCompile(`^(.*?)((\[\d+\])+)$`)
+
+// dotToPath turns a key in dot notation to a jq path. Every key segment is
+// quoted so dashes or symbols like "$" are not parsed by jq, except the list
+// indexes at its end.
+func dotToPath(key string) string {
+	path := ""
+	for _, segment := range strings.Split(key, ".") {
+		indexes := ""
+		if m := indexSuffix.FindStringSubmatch(segment); m != nil && m[1] != "" {
+			segment, indexes = m[1], m[2]
+		}
+		path += fmt.Sprintf(".%q", segment) + indexes
+	}
+	return path
+}
+
 // ToYAMLMap turns a map string interface which describes a yaml file in 'dot.yaml' format to a fully deep marshalled yaml.
 func ToYAMLMap(v map[string]interface{}) (map[string]interface{}, error) {
 	result := map[string]interface{}{}